@@ -0,0 +1,281 @@
+package archiver
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+)
+
+// Format is the archive file format.
+type Format string
+
+// Supported archive formats.
+const (
+	FormatZip    Format = "zip"
+	FormatTarGz  Format = "tar.gz"
+	FormatTarZst Format = "tar.zst"
+)
+
+// Extension returns the file extension of the format, including the leading dot.
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Opts configures how an archive is created.
+type Opts struct {
+	// Format defaults to FormatZip.
+	Format Format
+	// CompressionLevel is between 0 (no compression) and 9 (best compression).
+	// zstd has no uncompressed mode, levels are mapped to the closest zstd encoder level.
+	CompressionLevel int
+	// Excludes are glob patterns (see path.Match) matched against the slash separated path of every file
+	// relative to the source directory and against its base name. Excluded directories are skipped entirely.
+	Excludes []string
+	// Reproducible makes the archive depend only on the archived paths and contents:
+	// entries get normalized permissions and ownership (the modification times are fixed in every archive).
+	Reproducible bool
+	// Prefix is prepended to the name of every archived entry, for example Payload/ for an ipa.
+	Prefix string
+}
+
+// ReproducibleModTime is the modification time of every archive entry (the zip, MS-DOS epoch),
+// so that the archive does not depend on when the build products were written.
+var ReproducibleModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Archiver creates an archive from the given entries (files or directories relative to srcDir).
+type Archiver interface {
+	Archive(srcDir string, entries []string, dstPth string, opts Opts) error
+}
+
+type fileArchiver struct {
+	logger log.Logger
+}
+
+// NewArchiver returns an Archiver creating zip, tar.gz and tar.zst archives in pure Go.
+func NewArchiver(logger log.Logger) Archiver {
+	return fileArchiver{
+		logger: logger,
+	}
+}
+
+// Archive writes the entries into an archive at dstPth, overwriting any existing file.
+// Entries are added in lexical order, symlinks are stored as symlinks (like `zip --symlinks`).
+func (a fileArchiver) Archive(srcDir string, entries []string, dstPth string, opts Opts) error {
+	if opts.CompressionLevel < 0 || opts.CompressionLevel > 9 {
+		return fmt.Errorf("invalid compression level (%d), valid values are between 0 and 9", opts.CompressionLevel)
+	}
+
+	var write func(w io.Writer, files []file, opts Opts, progress *progress) (int64, error)
+	switch opts.Format {
+	case "", FormatZip:
+		write = a.writeZip
+	case FormatTarGz, FormatTarZst:
+		write = a.writeTar
+	default:
+		return fmt.Errorf("unsupported archive format: %s", opts.Format)
+	}
+
+	files, err := collectFiles(srcDir, entries, opts.Excludes)
+	if err != nil {
+		return err
+	}
+	if opts.Prefix != "" {
+		files = prefixedFiles(strings.TrimSuffix(opts.Prefix, "/"), files)
+	}
+
+	archiveFile, err := os.Create(dstPth)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dstPth, err)
+	}
+
+	size, err := write(archiveFile, files, opts, newProgress(a.logger, files))
+	if closeErr := archiveFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// do not leave a partial archive behind
+		if removeErr := os.Remove(dstPth); removeErr != nil {
+			a.logger.Warnf("Failed to remove %s: %s", dstPth, removeErr)
+		}
+		return fmt.Errorf("failed to write %s: %w", dstPth, err)
+	}
+
+	a.logger.Printf("Added %d entries (%d bytes) to %s", len(files), size, dstPth)
+	return nil
+}
+
+// progressStep is the percentage of the archived bytes between two progress logs.
+const progressStep = 10
+
+// progress logs the archived bytes of the regular files, every progressStep percent.
+type progress struct {
+	logger  log.Logger
+	total   int64
+	written int64
+	logged  int64
+}
+
+func newProgress(logger log.Logger, files []file) *progress {
+	var total int64
+	for _, f := range files {
+		if f.info.Mode().IsRegular() {
+			total += f.info.Size()
+		}
+	}
+	return &progress{logger: logger, total: total}
+}
+
+func (p *progress) add(f file, n int64) {
+	if p.total == 0 || !f.info.Mode().IsRegular() {
+		return
+	}
+
+	p.written += n
+	percent := p.written * 100 / p.total
+	if percent-p.logged < progressStep {
+		return
+	}
+	p.logged = percent - percent%progressStep
+	p.logger.Printf("Archived %d%% (%d of %d bytes)", p.logged, p.written, p.total)
+}
+
+// file is a file system node to be added to an archive.
+type file struct {
+	// name is the slash separated path relative to the source directory.
+	name string
+	pth  string
+	info os.FileInfo
+}
+
+// collectFiles walks the entries and returns every file, directory and symlink in lexical order.
+// Symlinks are not followed, so symlinked framework dirs (Versions/Current) are kept as symlinks.
+func collectFiles(srcDir string, entries []string, excludes []string) ([]file, error) {
+	if err := ValidatePatterns(excludes); err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+
+	sortedEntries := append([]string{}, entries...)
+	sort.Strings(sortedEntries)
+
+	seen := map[string]bool{}
+	var files []file
+	for _, entry := range sortedEntries {
+		entryPth := filepath.Join(srcDir, entry)
+		if err := filepath.Walk(entryPth, func(pth string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(srcDir, pth)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+
+			if Match(name, excludes) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if seen[name] {
+				return nil
+			}
+			seen[name] = true
+
+			files = append(files, file{name: name, pth: pth, info: info})
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", entryPth, err)
+		}
+	}
+
+	return files, nil
+}
+
+// prefixedFiles prepends the prefix and its parent directories (as directory entries) to the files.
+func prefixedFiles(prefix string, files []file) []file {
+	var prefixed []file
+	for dir := prefix; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		prefixed = append([]file{{name: dir, info: dirInfo{name: path.Base(dir)}}}, prefixed...)
+	}
+
+	for _, f := range files {
+		f.name = prefix + "/" + f.name
+		prefixed = append(prefixed, f)
+	}
+	return prefixed
+}
+
+// dirInfo describes a directory which only exists in the archive.
+type dirInfo struct {
+	name string
+}
+
+func (i dirInfo) Name() string       { return i.name }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (i dirInfo) ModTime() time.Time { return ReproducibleModTime }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() interface{}   { return nil }
+
+// ValidatePatterns checks that every pattern is a well-formed glob pattern.
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%s: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether the slash separated relative path or its base name matches any of the glob patterns.
+func Match(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, name); match {
+			return true
+		}
+		if match, _ := path.Match(pattern, path.Base(name)); match {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizedMode returns the permissions of a reproducible archive entry: 0755 for directories and executables,
+// 0644 for other files and 0777 for symlinks.
+func normalizedMode(mode os.FileMode) os.FileMode {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return os.ModeSymlink | 0777
+	case mode.IsDir():
+		return os.ModeDir | 0755
+	case mode&0111 != 0:
+		return 0755
+	default:
+		return 0644
+	}
+}
+
+func (a fileArchiver) copyFileContent(w io.Writer, f file) (int64, error) {
+	if !f.info.Mode().IsRegular() {
+		return 0, fmt.Errorf("unsupported file type: %s", f.info.Mode().Type())
+	}
+
+	a.logger.Debugf("Adding %s", f.name)
+
+	r, err := os.Open(f.pth)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	return io.Copy(w, r)
+}
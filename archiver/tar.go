@@ -0,0 +1,101 @@
+package archiver
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func (a fileArchiver) writeTar(w io.Writer, files []file, opts Opts, progress *progress) (int64, error) {
+	compressor, err := newTarCompressor(w, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	tarWriter := tar.NewWriter(compressor)
+
+	var size int64
+	for _, f := range files {
+		n, err := a.addTarFile(tarWriter, f, opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add %s: %w", f.name, err)
+		}
+		size += n
+		progress.add(f, n)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return 0, err
+	}
+	return size, compressor.Close()
+}
+
+func newTarCompressor(w io.Writer, opts Opts) (io.WriteCloser, error) {
+	if opts.Format == FormatTarZst {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstdEncoderLevel(opts.CompressionLevel)))
+	}
+
+	gzipWriter, err := gzip.NewWriterLevel(w, opts.CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	gzipWriter.ModTime = ReproducibleModTime
+	return gzipWriter, nil
+}
+
+// zstdEncoderLevel maps the 0-9 compression level to the closest zstd encoder level.
+func zstdEncoderLevel(compressionLevel int) zstd.EncoderLevel {
+	switch {
+	case compressionLevel <= 2:
+		return zstd.SpeedFastest
+	case compressionLevel <= 5:
+		return zstd.SpeedDefault
+	case compressionLevel <= 8:
+		return zstd.SpeedBetterCompression
+	default:
+		return zstd.SpeedBestCompression
+	}
+}
+
+func (a fileArchiver) addTarFile(tarWriter *tar.Writer, f file, opts Opts) (int64, error) {
+	var linkTarget string
+	if f.info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(f.pth)
+		if err != nil {
+			return 0, err
+		}
+		linkTarget = target
+	}
+
+	header, err := tar.FileInfoHeader(f.info, linkTarget)
+	if err != nil {
+		return 0, err
+	}
+	header.Name = f.name
+	if f.info.IsDir() {
+		header.Name += "/"
+	}
+	header.ModTime = ReproducibleModTime
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	if opts.Reproducible {
+		header.Mode = int64(normalizedMode(f.info.Mode()).Perm())
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
+	}
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return 0, err
+	}
+
+	if !f.info.Mode().IsRegular() {
+		return 0, nil
+	}
+
+	return a.copyFileContent(tarWriter, f)
+}
@@ -0,0 +1,141 @@
+package archiver
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenTarFormats_WhenArchive_ThenTarContainsEntriesAndSymlinks(t *testing.T) {
+	for _, format := range []Format{FormatTarGz, FormatTarZst} {
+		t.Run(string(format), func(t *testing.T) {
+			// Given
+			srcDir := createBuildProducts(t)
+			archivePth := filepath.Join(t.TempDir(), "testbundle"+format.Extension())
+
+			// When
+			err := NewArchiver(log.NewLogger()).Archive(srcDir, []string{"Debug-iphonesimulator"}, archivePth, Opts{
+				Format:           format,
+				CompressionLevel: 6,
+				Excludes:         []string{"*.swiftmodule"},
+			})
+
+			// Then
+			require.NoError(t, err)
+
+			headers := readTarHeaders(t, archivePth, format)
+			var names []string
+			links := map[string]string{}
+			for _, header := range headers {
+				names = append(names, header.Name)
+				require.True(t, header.ModTime.Equal(ReproducibleModTime), header.Name)
+				if header.Typeflag == tar.TypeSymlink {
+					links[header.Name] = header.Linkname
+				}
+			}
+			require.Equal(t, []string{
+				"Debug-iphonesimulator/",
+				"Debug-iphonesimulator/BullsEye.app/",
+				"Debug-iphonesimulator/BullsEye.app/BullsEye",
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/",
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/",
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Core",
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/",
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/A/",
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/A/Core",
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/Current",
+				"Debug-iphonesimulator/BullsEye.app/Info.plist",
+			}, names)
+			require.Equal(t, map[string]string{
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Core":             "Versions/Current/Core",
+				"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/Current": "A",
+			}, links)
+		})
+	}
+}
+
+func Test_GivenReproducibleTarGz_WhenArchivingChangedMetadata_ThenArchivesAreIdentical(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	archiver := NewArchiver(log.NewLogger())
+	opts := Opts{Format: FormatTarGz, CompressionLevel: 6, Reproducible: true}
+
+	firstPth := filepath.Join(t.TempDir(), "testbundle.tar.gz")
+	require.NoError(t, archiver.Archive(srcDir, []string{"Debug-iphonesimulator"}, firstPth, opts))
+
+	executablePth := filepath.Join(srcDir, "Debug-iphonesimulator", "BullsEye.app", "BullsEye")
+	require.NoError(t, os.Chtimes(executablePth, time.Now(), time.Now().Add(time.Hour)))
+	require.NoError(t, os.Chmod(executablePth, 0600))
+
+	// When
+	secondPth := filepath.Join(t.TempDir(), "testbundle.tar.gz")
+	err := archiver.Archive(srcDir, []string{"Debug-iphonesimulator"}, secondPth, opts)
+
+	// Then
+	require.NoError(t, err)
+
+	first, err := os.ReadFile(firstPth)
+	require.NoError(t, err)
+	second, err := os.ReadFile(secondPth)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	for _, header := range readTarHeaders(t, secondPth, FormatTarGz) {
+		require.True(t, header.ModTime.Equal(ReproducibleModTime), header.Name)
+		require.Equal(t, 0, header.Uid, header.Name)
+		if header.Name == "Debug-iphonesimulator/BullsEye.app/BullsEye" {
+			require.Equal(t, int64(0644), header.Mode)
+		}
+	}
+}
+
+func Test_GivenUnsupportedFormat_WhenArchive_ThenFails(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	archivePth := filepath.Join(t.TempDir(), "testbundle.rar")
+
+	// When
+	err := NewArchiver(log.NewLogger()).Archive(srcDir, []string{"Debug-iphonesimulator"}, archivePth, Opts{Format: "rar", CompressionLevel: 6})
+
+	// Then
+	require.EqualError(t, err, "unsupported archive format: rar")
+}
+
+func readTarHeaders(t *testing.T, archivePth string, format Format) []*tar.Header {
+	f, err := os.Open(archivePth)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader
+	if format == FormatTarZst {
+		zstdReader, err := zstd.NewReader(f)
+		require.NoError(t, err)
+		defer zstdReader.Close()
+		r = zstdReader
+	} else {
+		gzipReader, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		defer gzipReader.Close()
+		r = gzipReader
+	}
+
+	var headers []*tar.Header
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		headers = append(headers, header)
+	}
+	return headers
+}
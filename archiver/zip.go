@@ -0,0 +1,70 @@
+package archiver
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+)
+
+func (a fileArchiver) writeZip(w io.Writer, files []file, opts Opts, progress *progress) (int64, error) {
+	zipWriter := zip.NewWriter(w)
+	zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, opts.CompressionLevel)
+	})
+
+	var size int64
+	for _, f := range files {
+		n, err := a.addZipFile(zipWriter, f, opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add %s: %w", f.name, err)
+		}
+		size += n
+		progress.add(f, n)
+	}
+
+	return size, zipWriter.Close()
+}
+
+func (a fileArchiver) addZipFile(zipWriter *zip.Writer, f file, opts Opts) (int64, error) {
+	header, err := zip.FileInfoHeader(f.info)
+	if err != nil {
+		return 0, err
+	}
+	header.Name = f.name
+	header.Modified = ReproducibleModTime
+	if opts.Reproducible {
+		header.SetMode(normalizedMode(f.info.Mode()))
+	}
+
+	switch {
+	case f.info.IsDir():
+		header.Name += "/"
+		header.Method = zip.Store
+	case opts.CompressionLevel == flate.NoCompression || f.info.Mode()&os.ModeSymlink != 0:
+		header.Method = zip.Store
+	default:
+		header.Method = zip.Deflate
+	}
+
+	w, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return 0, err
+	}
+
+	if f.info.IsDir() {
+		return 0, nil
+	}
+
+	if f.info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(f.pth)
+		if err != nil {
+			return 0, err
+		}
+		n, err := io.WriteString(w, target)
+		return int64(n), err
+	}
+
+	return a.copyFileContent(w, f)
+}
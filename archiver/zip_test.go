@@ -0,0 +1,297 @@
+package archiver
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenBuildProducts_WhenArchive_ThenZipContainsEntriesInOrder(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	zipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+
+	// When
+	err := NewArchiver(log.NewLogger()).Archive(srcDir, []string{"Debug-iphonesimulator", "BullsEye.xctestrun"}, zipPth, Opts{CompressionLevel: 9})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BullsEye.xctestrun",
+		"Debug-iphonesimulator/",
+		"Debug-iphonesimulator/BullsEye.app/",
+		"Debug-iphonesimulator/BullsEye.app/BullsEye",
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/",
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/",
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Core",
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/",
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/A/",
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/A/Core",
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/Current",
+		"Debug-iphonesimulator/BullsEye.app/Info.plist",
+		"Debug-iphonesimulator/BullsEye.swiftmodule/",
+		"Debug-iphonesimulator/BullsEye.swiftmodule/arm64.swiftmodule",
+	}, zipEntryNames(t, zipPth))
+}
+
+func Test_GivenFrameworkSymlinks_WhenArchive_ThenSymlinksArePreserved(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	zipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+
+	// When
+	err := NewArchiver(log.NewLogger()).Archive(srcDir, []string{"Debug-iphonesimulator"}, zipPth, Opts{CompressionLevel: 6})
+
+	// Then
+	require.NoError(t, err)
+
+	r, err := zip.OpenReader(zipPth)
+	require.NoError(t, err)
+	defer r.Close()
+
+	links := map[string]string{}
+	for _, f := range r.File {
+		if f.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		rc, err := f.Open()
+		require.NoError(t, err)
+		target, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		links[f.Name] = string(target)
+	}
+	require.Equal(t, map[string]string{
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Core":             "Versions/Current/Core",
+		"Debug-iphonesimulator/BullsEye.app/Frameworks/Core.framework/Versions/Current": "A",
+	}, links)
+}
+
+func Test_GivenExcludePatterns_WhenArchive_ThenExcludedFilesAreSkipped(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	zipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+
+	// When
+	err := NewArchiver(log.NewLogger()).Archive(srcDir, []string{"Debug-iphonesimulator"}, zipPth, Opts{
+		CompressionLevel: 0,
+		Excludes:         []string{"*.swiftmodule", "Debug-iphonesimulator/BullsEye.app/Frameworks"},
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Debug-iphonesimulator/",
+		"Debug-iphonesimulator/BullsEye.app/",
+		"Debug-iphonesimulator/BullsEye.app/BullsEye",
+		"Debug-iphonesimulator/BullsEye.app/Info.plist",
+	}, zipEntryNames(t, zipPth))
+}
+
+func Test_GivenInvalidOpts_WhenArchive_ThenFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Opts
+		wantErr string
+	}{
+		{
+			name:    "compression level out of range",
+			opts:    Opts{CompressionLevel: 10},
+			wantErr: "invalid compression level (10), valid values are between 0 and 9",
+		},
+		{
+			name:    "malformed exclude pattern",
+			opts:    Opts{CompressionLevel: 6, Excludes: []string{"["}},
+			wantErr: "invalid exclude pattern: [: syntax error in pattern",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := createBuildProducts(t)
+			zipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+
+			err := NewArchiver(log.NewLogger()).Archive(srcDir, []string{"Debug-iphonesimulator"}, zipPth, tt.opts)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func Test_GivenUnsupportedFileType_WhenArchive_ThenNoPartialArchiveIsLeft(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	require.NoError(t, syscall.Mkfifo(filepath.Join(srcDir, "Debug-iphonesimulator", "BullsEye.app", "pipe"), 0644))
+	zipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+
+	// When
+	err := NewArchiver(log.NewLogger()).Archive(srcDir, []string{"Debug-iphonesimulator"}, zipPth, Opts{})
+
+	// Then
+	require.Error(t, err)
+	require.NoFileExists(t, zipPth)
+}
+
+func Test_GivenReproducibleOpt_WhenArchivingChangedMetadata_ThenArchivesAreIdentical(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	archiver := NewArchiver(log.NewLogger())
+	opts := Opts{CompressionLevel: 6, Reproducible: true}
+	entries := []string{"BullsEye.xctestrun", "Debug-iphonesimulator"}
+
+	firstZipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+	require.NoError(t, archiver.Archive(srcDir, entries, firstZipPth, opts))
+
+	infoPlistPth := filepath.Join(srcDir, "Debug-iphonesimulator", "BullsEye.app", "Info.plist")
+	require.NoError(t, os.Chtimes(infoPlistPth, time.Now(), time.Now().Add(time.Hour)))
+	require.NoError(t, os.Chmod(infoPlistPth, 0600))
+
+	// When
+	secondZipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+	err := archiver.Archive(srcDir, []string{"Debug-iphonesimulator", "BullsEye.xctestrun"}, secondZipPth, opts)
+
+	// Then
+	require.NoError(t, err)
+
+	first, err := os.ReadFile(firstZipPth)
+	require.NoError(t, err)
+	second, err := os.ReadFile(secondZipPth)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	r, err := zip.OpenReader(secondZipPth)
+	require.NoError(t, err)
+	defer r.Close()
+	for _, f := range r.File {
+		require.True(t, f.Modified.Equal(ReproducibleModTime), f.Name)
+		if f.Name == "Debug-iphonesimulator/BullsEye.app/Info.plist" {
+			require.Equal(t, os.FileMode(0644), f.Mode())
+		}
+	}
+}
+
+func Test_GivenChangedModificationTime_WhenArchive_ThenArchivesAreIdentical(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	archiver := NewArchiver(log.NewLogger())
+	opts := Opts{CompressionLevel: 6}
+
+	firstZipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+	require.NoError(t, archiver.Archive(srcDir, []string{"Debug-iphonesimulator"}, firstZipPth, opts))
+
+	infoPlistPth := filepath.Join(srcDir, "Debug-iphonesimulator", "BullsEye.app", "Info.plist")
+	require.NoError(t, os.Chtimes(infoPlistPth, time.Now(), time.Now().Add(time.Hour)))
+
+	// When
+	secondZipPth := filepath.Join(t.TempDir(), "testbundle.zip")
+	err := archiver.Archive(srcDir, []string{"Debug-iphonesimulator"}, secondZipPth, opts)
+
+	// Then
+	require.NoError(t, err)
+
+	first, err := os.ReadFile(firstZipPth)
+	require.NoError(t, err)
+	second, err := os.ReadFile(secondZipPth)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+}
+
+func Test_GivenBuildProducts_WhenArchive_ThenProgressIsLogged(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	logger := &recordingLogger{Logger: log.NewLogger()}
+
+	// When
+	err := NewArchiver(logger).Archive(srcDir, []string{"Debug-iphonesimulator", "BullsEye.xctestrun"}, filepath.Join(t.TempDir(), "testbundle.zip"), Opts{CompressionLevel: 6})
+
+	// Then
+	require.NoError(t, err)
+
+	var progress []string
+	for _, message := range logger.messages {
+		if strings.HasPrefix(message, "Archived") {
+			progress = append(progress, message)
+		}
+	}
+	require.Equal(t, []string{
+		"Archived 10% (9 of 50 bytes)",
+		"Archived 30% (19 of 50 bytes)",
+		"Archived 70% (39 of 50 bytes)",
+		"Archived 80% (44 of 50 bytes)",
+		"Archived 100% (50 of 50 bytes)",
+	}, progress)
+}
+
+// recordingLogger records the messages logged with Printf.
+type recordingLogger struct {
+	log.Logger
+	messages []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func Test_GivenPrefix_WhenArchive_ThenEntriesAreNestedUnderPrefix(t *testing.T) {
+	// Given
+	srcDir := createBuildProducts(t)
+	ipaPth := filepath.Join(t.TempDir(), "BullsEye.ipa")
+
+	// When
+	err := NewArchiver(log.NewLogger()).Archive(filepath.Join(srcDir, "Debug-iphonesimulator"), []string{"BullsEye.app"}, ipaPth, Opts{
+		CompressionLevel: 6,
+		Excludes:         []string{"Frameworks"},
+		Prefix:           "Payload/",
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Payload/",
+		"Payload/BullsEye.app/",
+		"Payload/BullsEye.app/BullsEye",
+		"Payload/BullsEye.app/Info.plist",
+	}, zipEntryNames(t, ipaPth))
+}
+
+// createBuildProducts creates a minimal build root (SYMROOT) with an app embedding a versioned framework.
+func createBuildProducts(t *testing.T) string {
+	srcDir := t.TempDir()
+
+	appDir := filepath.Join(srcDir, "Debug-iphonesimulator", "BullsEye.app")
+	frameworkDir := filepath.Join(appDir, "Frameworks", "Core.framework")
+	writeFile(t, filepath.Join(appDir, "BullsEye"), "executable")
+	writeFile(t, filepath.Join(appDir, "Info.plist"), "plist")
+	writeFile(t, filepath.Join(frameworkDir, "Versions", "A", "Core"), "framework executable")
+	require.NoError(t, os.Symlink("A", filepath.Join(frameworkDir, "Versions", "Current")))
+	require.NoError(t, os.Symlink(filepath.Join("Versions", "Current", "Core"), filepath.Join(frameworkDir, "Core")))
+	writeFile(t, filepath.Join(srcDir, "Debug-iphonesimulator", "BullsEye.swiftmodule", "arm64.swiftmodule"), "module")
+	writeFile(t, filepath.Join(srcDir, "BullsEye.xctestrun"), "xctestrun")
+
+	return srcDir
+}
+
+func writeFile(t *testing.T, pth, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+	require.NoError(t, os.WriteFile(pth, []byte(content), 0644))
+}
+
+func zipEntryNames(t *testing.T, zipPth string) []string {
+	r, err := zip.OpenReader(zipPth)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/bitrise-io/go-steputils/v2/ruby"
 	"github.com/bitrise-io/go-utils/v2/command"
@@ -13,6 +14,7 @@ import (
 	"github.com/bitrise-io/go-utils/v2/pathutil"
 	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
 	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
 	"github.com/bitrise-steplib/steps-xcode-build-for-test/step"
 	"github.com/bitrise-steplib/steps-xcode-build-for-test/xcodeproject"
 )
@@ -29,7 +31,7 @@ func run() int {
 		return 1
 	}
 
-	builder, err := createXcodebuildBuilder(logger, config.LogFormatter)
+	builder, err := createXcodebuildBuilder(logger, config.LogFormatter, config.PackageResolution.Timeout)
 	if err != nil {
 		logger.Errorf("%s", errorutil.FormattedError(fmt.Errorf("failed to process Step inputs: %w", err)))
 		return 1
@@ -58,7 +60,7 @@ func createConfigParser(logger log.Logger) step.ConfigParser {
 	return step.NewConfigParser(logger)
 }
 
-func createXcodebuildBuilder(logger log.Logger, logFormatter string) (step.XcodebuildBuilder, error) {
+func createXcodebuildBuilder(logger log.Logger, logFormatter string, packageResolutionTimeout time.Duration) (step.XcodebuildBuilder, error) {
 	envRepository := env.NewRepository()
 	pathProvider := pathutil.NewPathProvider()
 	pathChecker := pathutil.NewPathChecker()
@@ -98,6 +100,9 @@ func createXcodebuildBuilder(logger log.Logger, logFormatter string) (step.Xcode
 		step.NewFileManager(),
 		logger,
 		cmdFactory,
+		archiver.NewArchiver(logger),
+		step.NewSleeper(),
+		xcodecommand.NewRawCommandRunner(logger, step.NewTimeoutCommandFactory(envRepository, packageResolutionTimeout)),
 	), nil
 }
 
@@ -106,5 +111,15 @@ func createExportOptions(config step.Config, result step.RunOut) step.ExportOpts
 		RunOut:           result,
 		OutputDir:        config.OutputDir,
 		CompressionLevel: config.CompressionLevel,
+		Packaging:        config.Packaging,
+		Reproducible:     config.Reproducible,
+		ArchiveFormat:    config.ArchiveFormat,
+		Prune:            config.Prune,
+		PruneAllowList:   config.PruneAllowList,
+		PruneDenyList:    config.PruneDenyList,
+		ExportDSYMs:      config.ExportDSYMs,
+		TestInventory:    config.TestInventory,
+		PackagingProfile: config.PackagingProfile,
+		IPAExport:        config.IPAExport,
 	}
 }
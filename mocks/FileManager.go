@@ -162,6 +162,57 @@ func (_c *FileManager_ReadFile_Call) RunAndReturn(run func(pth string) ([]byte,
 	return _c
 }
 
+// Remove provides a mock function for the type FileManager
+func (_mock *FileManager) Remove(name string) error {
+	ret := _mock.Called(name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Remove")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string) error); ok {
+		r0 = returnFunc(name)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// FileManager_Remove_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Remove'
+type FileManager_Remove_Call struct {
+	*mock.Call
+}
+
+// Remove is a helper method to define mock.On call
+//   - name string
+func (_e *FileManager_Expecter) Remove(name interface{}) *FileManager_Remove_Call {
+	return &FileManager_Remove_Call{Call: _e.mock.On("Remove", name)}
+}
+
+func (_c *FileManager_Remove_Call) Run(run func(name string)) *FileManager_Remove_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *FileManager_Remove_Call) Return(err error) *FileManager_Remove_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *FileManager_Remove_Call) RunAndReturn(run func(name string) error) *FileManager_Remove_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // WriteFile provides a mock function for the type FileManager
 func (_mock *FileManager) WriteFile(filename string, data []byte, perm fs.FileMode) error {
 	ret := _mock.Called(filename, data, perm)
@@ -0,0 +1,50 @@
+package step
+
+import (
+	"path/filepath"
+)
+
+const (
+	awsDeviceFarmAppPathEnvKey         = "BITRISE_AWS_DEVICE_FARM_APP_PATH"
+	awsDeviceFarmTestPackagePathEnvKey = "BITRISE_AWS_DEVICE_FARM_TEST_PACKAGE_PATH"
+)
+
+// awsDeviceFarmPackager creates the app under test as an ipa, and the test packages:
+// the UI test runner app (*-Runner.app) as an ipa for XCTest UI tests, the zipped test bundle (.xctest) for XCTest unit tests.
+type awsDeviceFarmPackager struct {
+	builder XcodebuildBuilder
+}
+
+func (p awsDeviceFarmPackager) Package(opts ExportOpts, outputDir string) (PackagedArtifacts, error) {
+	products, err := p.builder.collectTestProducts(opts.SYMRoot, opts.XctestrunPths)
+	if err != nil {
+		return nil, err
+	}
+
+	apps, testPackages := artifactCache{}, artifactCache{}
+	for _, product := range products {
+		if product.App != "" {
+			ipaPth := ipaPath(outputDir, product.App)
+			if err := apps.create(ipaPth, func() error { return p.builder.createIPA(product.App, ipaPth, opts) }); err != nil {
+				return nil, err
+			}
+		}
+
+		if product.IsUITestBundle {
+			ipaPth := ipaPath(outputDir, product.Runner)
+			if err := testPackages.create(ipaPth, func() error { return p.builder.createIPA(product.Runner, ipaPth, opts) }); err != nil {
+				return nil, err
+			}
+		} else {
+			zipPth := filepath.Join(outputDir, filepath.Base(product.TestBundle)+".zip")
+			if err := testPackages.create(zipPth, func() error { return p.builder.zipProduct(product.TestBundle, zipPth, opts) }); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return PackagedArtifacts{
+		awsDeviceFarmAppPathEnvKey:         apps.sorted(),
+		awsDeviceFarmTestPackagePathEnvKey: testPackages.sorted(),
+	}, nil
+}
@@ -0,0 +1,47 @@
+package step
+
+import (
+	"path/filepath"
+)
+
+const (
+	browserStackAppPathEnvKey       = "BITRISE_BROWSERSTACK_APP_PATH"
+	browserStackTestSuitePathEnvKey = "BITRISE_BROWSERSTACK_TEST_SUITE_PATH"
+)
+
+// browserStackPackager creates the app under test as an ipa, and the test suites as zips:
+// the UI test runner app (*-Runner.app) for UI tests, the test bundle (.xctest) for unit tests.
+type browserStackPackager struct {
+	builder XcodebuildBuilder
+}
+
+func (p browserStackPackager) Package(opts ExportOpts, outputDir string) (PackagedArtifacts, error) {
+	products, err := p.builder.collectTestProducts(opts.SYMRoot, opts.XctestrunPths)
+	if err != nil {
+		return nil, err
+	}
+
+	apps, testSuites := artifactCache{}, artifactCache{}
+	for _, product := range products {
+		if product.App != "" {
+			ipaPth := ipaPath(outputDir, product.App)
+			if err := apps.create(ipaPth, func() error { return p.builder.createIPA(product.App, ipaPth, opts) }); err != nil {
+				return nil, err
+			}
+		}
+
+		testSuite := product.TestBundle
+		if product.IsUITestBundle {
+			testSuite = product.Runner
+		}
+		zipPth := filepath.Join(outputDir, filepath.Base(testSuite)+".zip")
+		if err := testSuites.create(zipPth, func() error { return p.builder.zipProduct(testSuite, zipPth, opts) }); err != nil {
+			return nil, err
+		}
+	}
+
+	return PackagedArtifacts{
+		browserStackAppPathEnvKey:       apps.sorted(),
+		browserStackTestSuitePathEnvKey: testSuites.sorted(),
+	}, nil
+}
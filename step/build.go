@@ -2,27 +2,19 @@ package step
 
 import (
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/bitrise-io/go-utils/colorstring"
 	"github.com/bitrise-io/go-utils/stringutil"
 	"github.com/bitrise-io/go-utils/v2/log"
 	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
 	"github.com/bitrise-io/go-xcode/xcodebuild"
-	cache "github.com/bitrise-io/go-xcode/xcodecache"
 )
 
-func runCommandWithRetry(xcodeCommandRunner xcodecommand.Runner, logFormatter string, cmd *xcodebuild.CommandBuilder, swiftPackagesPath string, logger log.Logger) (string, error) {
-	output, err := runCommand(xcodeCommandRunner, logFormatter, cmd, logger)
-	if err != nil && swiftPackagesPath != "" && strings.Contains(output, cache.SwiftPackagesStateInvalid) {
-		logger.Warnf("Build failed, swift packages cache is in an invalid state, error: %s", err)
-		if err := os.RemoveAll(swiftPackagesPath); err != nil {
-			return output, fmt.Errorf("failed to remove invalid Swift package caches, error: %s", err)
-		}
+// runCommandWithRetry runs the build, and retries it as long as it fails with a transient failure of the retry policy.
+func runCommandWithRetry(xcodeCommandRunner xcodecommand.Runner, logFormatter string, cmd *xcodebuild.CommandBuilder, policy retryPolicy, paths retryPaths, sleeper Sleeper, logger log.Logger) (string, error) {
+	return runWithRetry(func() (string, error) {
 		return runCommand(xcodeCommandRunner, logFormatter, cmd, logger)
-	}
-	return output, err
+	}, policy, paths, sleeper, logger)
 }
 
 func runCommand(xcodeCommandRunner xcodecommand.Runner, logFormatter string, cmd *xcodebuild.CommandBuilder, logger log.Logger) (string, error) {
@@ -0,0 +1,120 @@
+package step
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/output"
+	"github.com/bitrise-io/go-xcode/v2/errorfinder"
+)
+
+const (
+	buildErrorsPathEnvKey = "BITRISE_BUILD_ERRORS_PATH"
+	buildErrorsBaseName   = "build-errors.json"
+
+	// maxReportedBuildErrors is the number of build errors listed in the Step error, every error is listed in the build errors file.
+	maxReportedBuildErrors = 10
+)
+
+// compilerErrorPattern matches the compiler and linker diagnostics with a source location:
+// /Users/vagrant/git/BullsEye/BullsEye/ContentView.swift:42:17: error: cannot find 'score' in scope
+var compilerErrorPattern = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?: (?:fatal )?error: (.+)$`)
+
+type buildErrorsReport struct {
+	Category failureCategory `json:"category,omitempty"`
+	Hint     string          `json:"hint,omitempty"`
+	Errors   []buildError    `json:"errors"`
+}
+
+// buildError is an error of the xcodebuild log, File, Line and Column are only set for errors with a source location.
+type buildError struct {
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+func (e buildError) String() string {
+	if e.File == "" {
+		return e.Message
+	}
+	location := fmt.Sprintf("%s:%d", e.File, e.Line)
+	if e.Column > 0 {
+		location += fmt.Sprintf(":%d", e.Column)
+	}
+	return location + ": " + e.Message
+}
+
+// findBuildErrors collects the errors of the xcodebuild log: error lines, xcodebuild errors and NSErrors.
+// Errors are reported multiple times by the compiler (for example once per architecture), they are deduplicated.
+func findBuildErrors(xcodebuildLog string) []buildError {
+	buildErrors := []buildError{}
+	found := map[buildError]bool{}
+	for _, line := range errorfinder.FindXcodebuildErrors(xcodebuildLog) {
+		buildErr := parseBuildError(strings.TrimSpace(line))
+		if found[buildErr] {
+			continue
+		}
+		found[buildErr] = true
+		buildErrors = append(buildErrors, buildErr)
+	}
+	return buildErrors
+}
+
+func parseBuildError(line string) buildError {
+	match := compilerErrorPattern.FindStringSubmatch(line)
+	if match == nil {
+		return buildError{Message: strings.TrimPrefix(line, "error: ")}
+	}
+
+	lineNumber, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	return buildError{
+		Message: match[4],
+		File:    match[1],
+		Line:    lineNumber,
+		Column:  column,
+	}
+}
+
+// wrapBuildError lists the build errors found in the xcodebuild log in the error of the failed build.
+func wrapBuildError(err error, buildErrors []buildError) error {
+	if len(buildErrors) == 0 {
+		return err
+	}
+
+	var lines []string
+	for i, buildErr := range buildErrors {
+		if i == maxReportedBuildErrors {
+			lines = append(lines, fmt.Sprintf("... and %d more, see %s", len(buildErrors)-maxReportedBuildErrors, buildErrorsBaseName))
+			break
+		}
+		lines = append(lines, "- "+buildErr.String())
+	}
+	return fmt.Errorf("%w\n%d build error(s):\n%s", err, len(buildErrors), strings.Join(lines, "\n"))
+}
+
+func (b XcodebuildBuilder) exportBuildErrors(outputDir string, buildErrors []buildError, failure *buildFailure) error {
+	report := buildErrorsReport{Errors: buildErrors}
+	if failure != nil {
+		report.Category = failure.Category
+		report.Hint = failure.Hint
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode build errors: %w", err)
+	}
+
+	buildErrorsPth := filepath.Join(outputDir, buildErrorsBaseName)
+	if err := output.ExportOutputFileContent(string(content), buildErrorsPth, buildErrorsPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", buildErrorsPathEnvKey, err)
+	}
+	b.logger.Donef("The build errors are available in %s env: %s", buildErrorsPathEnvKey, buildErrorsPth)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package step
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findBuildErrors(t *testing.T) {
+	xcodebuildLog := `CompileSwift normal arm64 /Users/vagrant/git/BullsEye/BullsEye/ContentView.swift
+/Users/vagrant/git/BullsEye/BullsEye/ContentView.swift:42:17: error: cannot find 'score' in scope
+        Text("\(score)")
+                ^~~~~
+CompileSwift normal x86_64 /Users/vagrant/git/BullsEye/BullsEye/ContentView.swift
+/Users/vagrant/git/BullsEye/BullsEye/ContentView.swift:42:17: error: cannot find 'score' in scope
+ld: warning: directory not found for option '-F/Users/vagrant/git/BullsEye/Frameworks'
+/Users/vagrant/git/BullsEye/BullsEye/Game.swift:7: error: expected declaration
+error: Signing for "BullsEyeTests" requires a development team. Select a development team in the Signing & Capabilities editor. (in target 'BullsEyeTests' from project 'BullsEye')
+
+** TEST BUILD FAILED **
+`
+
+	buildErrors := findBuildErrors(xcodebuildLog)
+
+	require.Equal(t, []buildError{
+		{Message: "cannot find 'score' in scope", File: "/Users/vagrant/git/BullsEye/BullsEye/ContentView.swift", Line: 42, Column: 17},
+		{Message: "expected declaration", File: "/Users/vagrant/git/BullsEye/BullsEye/Game.swift", Line: 7},
+		{Message: `Signing for "BullsEyeTests" requires a development team. Select a development team in the Signing & Capabilities editor. (in target 'BullsEyeTests' from project 'BullsEye')`},
+	}, buildErrors)
+}
+
+func Test_findBuildErrors_NoErrors(t *testing.T) {
+	require.Equal(t, []buildError{}, findBuildErrors("** TEST BUILD FAILED **"))
+}
+
+func Test_wrapBuildError(t *testing.T) {
+	buildErr := errors.New("exit status 65")
+
+	t.Run("no build errors", func(t *testing.T) {
+		require.Equal(t, buildErr, wrapBuildError(buildErr, nil))
+	})
+
+	t.Run("build errors", func(t *testing.T) {
+		err := wrapBuildError(buildErr, []buildError{
+			{Message: "cannot find 'score' in scope", File: "ContentView.swift", Line: 42, Column: 17},
+			{Message: "expected declaration", File: "Game.swift", Line: 7},
+		})
+
+		require.ErrorIs(t, err, buildErr)
+		require.EqualError(t, err, `exit status 65
+2 build error(s):
+- ContentView.swift:42:17: cannot find 'score' in scope
+- Game.swift:7: expected declaration`)
+	})
+
+	t.Run("too many build errors", func(t *testing.T) {
+		var buildErrors []buildError
+		for i := 0; i < maxReportedBuildErrors+3; i++ {
+			buildErrors = append(buildErrors, buildError{Message: "expected declaration", File: "Game.swift", Line: i + 1})
+		}
+
+		err := wrapBuildError(buildErr, buildErrors)
+
+		require.True(t, strings.HasSuffix(err.Error(), "... and 3 more, see build-errors.json"))
+	})
+}
@@ -0,0 +1,87 @@
+package step
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/output"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+)
+
+const (
+	testBundleDSYMZipPathEnvKey = "BITRISE_TEST_BUNDLE_DSYM_ZIP_PATH"
+	testBundleDSYMZipBaseName   = "testbundle.dSYM.zip"
+)
+
+// findTestBundleDSYMs returns the dSYMs of the host apps, test bundles and dependent products referenced by the xctestrun files,
+// relative to the build root (SYMROOT).
+//
+// Xcode generates the dSYMs next to the top level products, even for nested ones:
+//
+//	Debug-iphoneos/BullsEye.app
+//	Debug-iphoneos/BullsEye.app/PlugIns/BullsEyeTests.xctest
+//	Debug-iphoneos/BullsEye.app.dSYM
+//	Debug-iphoneos/BullsEyeTests.xctest.dSYM
+func (b XcodebuildBuilder) findTestBundleDSYMs(symRoot string, xctestrunPths []string) ([]string, error) {
+	dsymPths := map[string]bool{}
+	for _, xctestrunPth := range xctestrunPths {
+		testRun, err := b.readXctestrun(xctestrunPth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", xctestrunPth, err)
+		}
+
+		for _, target := range testRun.TestTargets() {
+			for _, productPath := range target.ProductPaths() {
+				if !isInTestBundle(productPath.Path) {
+					continue
+				}
+
+				productPth := relativeToSYMRoot(symRoot, target.ResolvePath(productPath.Path, symRoot))
+				productsDir := strings.Split(filepath.ToSlash(productPth), "/")[0]
+				dsymPth := filepath.Join(productsDir, filepath.Base(productPth)+".dSYM")
+				if dsymPths[dsymPth] {
+					continue
+				}
+
+				exists, err := b.pathChecker.IsDirExists(filepath.Join(symRoot, dsymPth))
+				if err != nil {
+					return nil, fmt.Errorf("failed to check if %s exists: %w", dsymPth, err)
+				}
+				if exists {
+					dsymPths[dsymPth] = true
+				}
+			}
+		}
+	}
+
+	return sortedKeys(dsymPths), nil
+}
+
+func (b XcodebuildBuilder) exportTestBundleDSYMs(opts ExportOpts) error {
+	dsymPths, err := b.findTestBundleDSYMs(opts.SYMRoot, opts.XctestrunPths)
+	if err != nil {
+		return err
+	}
+	if len(dsymPths) == 0 {
+		b.logger.Warnf("No dSYM found for the products referenced by the xctestrun file(s), make sure DEBUG_INFORMATION_FORMAT is set to dwarf-with-dsym")
+		return nil
+	}
+
+	dsymZipPth := filepath.Join(opts.OutputDir, testBundleDSYMZipBaseName)
+	b.logger.Printf("Archiving %s into %s", strings.Join(dsymPths, ", "), dsymZipPth)
+	if err := b.archiver.Archive(opts.SYMRoot, dsymPths, dsymZipPth, archiver.Opts{
+		Format:           archiver.FormatZip,
+		CompressionLevel: opts.CompressionLevel,
+		Reproducible:     opts.Reproducible,
+	}); err != nil {
+		return err
+	}
+
+	if err := output.ExportOutputFile(dsymZipPth, dsymZipPth, testBundleDSYMZipPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", testBundleDSYMZipPathEnvKey, err)
+	}
+	b.logger.Donef("The zipped dSYMs are available in %s env: %s", testBundleDSYMZipPathEnvKey, dsymZipPth)
+
+	return nil
+}
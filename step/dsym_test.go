@@ -0,0 +1,67 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenReferencedProductsWithDSYMs_WhenFindTestBundleDSYMs_ThenReturnsExistingDSYMs(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	xctestrunPth := "/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(data, nil)
+
+	existingDSYMs := map[string]bool{
+		"/symroot/Debug-iphonesimulator/BullsEye.app.dSYM":           true,
+		"/symroot/Debug-iphonesimulator/BullsEyeTests.xctest.dSYM":   true,
+		"/symroot/Debug-iphonesimulator/BullsEyeUITests.xctest.dSYM": true,
+	}
+	stepMocks.pathChecker.On("IsDirExists", mock.Anything).Return(func(pth string) bool {
+		return existingDSYMs[pth]
+	}, nil)
+
+	// When
+	dsymPths, err := step.findTestBundleDSYMs("/symroot", []string{xctestrunPth})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Debug-iphonesimulator/BullsEye.app.dSYM",
+		"Debug-iphonesimulator/BullsEyeTests.xctest.dSYM",
+		"Debug-iphonesimulator/BullsEyeUITests.xctest.dSYM",
+	}, dsymPths)
+	stepMocks.pathChecker.AssertCalled(t, "IsDirExists", "/symroot/Debug-iphonesimulator/BullsEyeUITests-Runner.app.dSYM")
+}
+
+func Test_GivenNoDSYMs_WhenExportTestBundleDSYMs_ThenSkipsArchiving(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Warnf", mock.Anything).Return()
+
+	xctestrunPth := "/symroot/BullsEye.xctestrun"
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(xctestrunContent("BullsEyeTests"), nil)
+	stepMocks.pathChecker.On("IsDirExists", mock.Anything).Return(false, nil)
+
+	opts := ExportOpts{
+		RunOut: RunOut{
+			SYMRoot:       "/symroot",
+			XctestrunPths: []string{xctestrunPth},
+		},
+		OutputDir: "/output",
+	}
+
+	// When
+	err := step.exportTestBundleDSYMs(opts)
+
+	// Then
+	require.NoError(t, err)
+	stepMocks.archiver.AssertNotCalled(t, "Archive", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	stepMocks.logger.AssertCalled(t, "Warnf", mock.Anything)
+}
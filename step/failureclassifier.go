@@ -0,0 +1,132 @@
+package step
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/tools"
+)
+
+const buildFailureCategoryEnvKey = "BITRISE_BUILD_FAILURE_CATEGORY"
+
+type failureCategory string
+
+const (
+	failureCategoryCodeSigning failureCategory = "code_signing"
+	failureCategoryDestination failureCategory = "destination"
+	failureCategorySPM         failureCategory = "spm"
+	failureCategoryScheme      failureCategory = "scheme"
+	failureCategoryCompile     failureCategory = "compile"
+	failureCategoryLink        failureCategory = "link"
+	failureCategoryInfra       failureCategory = "infra"
+	failureCategoryUnknown     failureCategory = "unknown"
+)
+
+// failureSignature is a known xcodebuild failure, recognized by a line of the xcodebuild log.
+type failureSignature struct {
+	Category failureCategory
+	Pattern  *regexp.Regexp
+	Hint     string
+}
+
+// failureSignatures are checked in order, the first matching signature classifies the failure.
+// Infrastructure issues come first as they cause further errors, and compile errors come before link errors
+// as the linker fails on the missing objects of the failed compilation.
+var failureSignatures = []failureSignature{
+	{
+		Category: failureCategoryInfra,
+		Pattern:  regexp.MustCompile(`(?m)^.*(No space left on device|Killed: 9|The network connection was lost|Could not connect to the server|Connection reset by peer|Timed out waiting).*$`),
+		Hint:     "The build failed because of an infrastructure issue, which is usually transient: retry the build. If it keeps failing, check the disk usage (for example caches restored into the build directory) and the network access of the build machine.",
+	},
+	{
+		Category: failureCategoryScheme,
+		Pattern:  regexp.MustCompile(`(?m)^.*(is not currently configured for the test action|is not configured for the test action|does not contain a scheme named|xcodebuild: error: The scheme .* does not exist).*$`),
+		Hint:     "Make sure the scheme is shared (Product > Scheme > Manage Schemes... > Shared), committed to the repository, and has test targets in its Test action (or a Test Plan).",
+	},
+	{
+		Category: failureCategoryDestination,
+		Pattern:  regexp.MustCompile(`(?m)^.*(Unable to find a destination matching the provided destination specifier|Unable to find a device matching the provided destination specifier|is not a valid destination specifier).*$`),
+		Hint:     "The Device destination specifier input doesn't match any available destination: use a generic destination (generic/platform=iOS Simulator or generic/platform=iOS), or make sure the simulator runtime is installed on the stack (xcrun simctl list).",
+	},
+	{
+		Category: failureCategorySPM,
+		Pattern:  regexp.MustCompile(`(?m)^.*(Could not resolve package dependencies|Failed to resolve dependencies|Package\.resolved file is corrupted|package dependencies could not be resolved|fatal: could not read Username).*$`),
+		Hint:     "Swift package dependencies couldn't be resolved: make sure Package.resolved is committed, the build machine can access every package repository (add an SSH key for private packages), and try clearing the Swift package cache.",
+	},
+	{
+		Category: failureCategoryCodeSigning,
+		Pattern:  regexp.MustCompile(`(?m)^.*(No profiles for '.*' were found|requires a provisioning profile|requires a development team|No signing certificate|No certificate for team|doesn't include signing certificate|doesn't match the entitlements file|Provisioning profile ".*" doesn't).*$`),
+		Hint:     "Code signing failed: set the Automatic code signing method input to manage the profiles automatically, or upload the certificate and provisioning profiles to the Code Signing tab. Simulator builds can skip signing with CODE_SIGNING_ALLOWED=NO.",
+	},
+	{
+		Category: failureCategoryCompile,
+		Pattern:  regexp.MustCompile(`(?m)^(\S.*:\d+(:\d+)?: (fatal )?error: .*|.*Command (CompileSwift|SwiftCompile|CompileC|SwiftEmitModule) failed.*)$`),
+		Hint:     "The sources failed to compile: fix the errors listed in the build errors output. If the project builds locally, make sure the stack's Xcode version matches the local one.",
+	},
+	{
+		Category: failureCategoryLink,
+		Pattern:  regexp.MustCompile(`(?m)^.*(Undefined symbols? for architecture|ld: symbol\(s\) not found|ld: library not found|ld: framework not found|linker command failed with exit code).*$`),
+		Hint:     "Linking failed: make sure every linked framework and library is built for the destination's architectures (check EXCLUDED_ARCHS and the dependency manager's output), and that the test targets link the modules they import.",
+	},
+}
+
+// buildFailure is the classification of a failed build.
+type buildFailure struct {
+	Category failureCategory
+	// Signature is the xcodebuild log line the failure was recognized by.
+	Signature string
+	Hint      string
+}
+
+// classifyBuildFailure matches the known failure signatures in the xcodebuild log.
+func classifyBuildFailure(xcodebuildLog string) buildFailure {
+	for _, signature := range failureSignatures {
+		if line := signature.Pattern.FindString(xcodebuildLog); line != "" {
+			return buildFailure{
+				Category:  signature.Category,
+				Signature: strings.TrimSpace(line),
+				Hint:      signature.Hint,
+			}
+		}
+	}
+
+	return buildFailure{
+		Category: failureCategoryUnknown,
+		Hint:     fmt.Sprintf("The failure doesn't match any known failure, check the errors in %s.", xcodebuildLogBaseName),
+	}
+}
+
+func (b XcodebuildBuilder) printBuildFailure(failure buildFailure) {
+	b.logger.Println()
+	b.logger.Errorf("Build failure category: %s", failure.Category)
+	if failure.Signature != "" {
+		b.logger.Printf("Recognized by: %s", failure.Signature)
+	}
+	b.logger.Warnf("Hint: %s", failure.Hint)
+}
+
+func (b XcodebuildBuilder) exportBuildFailureCategory(failure buildFailure) error {
+	if err := tools.ExportEnvironmentWithEnvman(buildFailureCategoryEnvKey, string(failure.Category)); err != nil {
+		return fmt.Errorf("failed to export %s: %w", buildFailureCategoryEnvKey, err)
+	}
+	b.logger.Donef("The build failure category is available in %s env: %s", buildFailureCategoryEnvKey, failure.Category)
+	return nil
+}
+
+// classifyPackageResolutionFailure classifies a failed Swift package resolution phase, which is always a Swift package failure:
+// the signature of the matching failure (for example a network issue) is kept, but the hint is the Swift package one.
+func classifyPackageResolutionFailure(resolutionLog string) buildFailure {
+	failure := classifyBuildFailure(resolutionLog)
+	if failure.Category == failureCategorySPM {
+		return failure
+	}
+
+	failure.Category = failureCategorySPM
+	for _, signature := range failureSignatures {
+		if signature.Category == failureCategorySPM {
+			failure.Hint = signature.Hint
+		}
+	}
+	return failure
+}
@@ -0,0 +1,73 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_classifyBuildFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		xcodebuildLog string
+		wantCategory  failureCategory
+		wantSignature string
+	}{
+		{
+			name:          "missing provisioning profile",
+			xcodebuildLog: "/Users/vagrant/git/BullsEye.xcodeproj: error: No profiles for 'io.bitrise.BullsEye' were found: Xcode couldn't find any iOS App Development provisioning profiles matching 'io.bitrise.BullsEye'. (in target 'BullsEye' from project 'BullsEye')\n** TEST BUILD FAILED **",
+			wantCategory:  failureCategoryCodeSigning,
+			wantSignature: "/Users/vagrant/git/BullsEye.xcodeproj: error: No profiles for 'io.bitrise.BullsEye' were found: Xcode couldn't find any iOS App Development provisioning profiles matching 'io.bitrise.BullsEye'. (in target 'BullsEye' from project 'BullsEye')",
+		},
+		{
+			name:          "unknown destination",
+			xcodebuildLog: "xcodebuild: error: Unable to find a destination matching the provided destination specifier:\n\t\t{ platform:iOS Simulator, OS:13.0, name:iPhone 8 }",
+			wantCategory:  failureCategoryDestination,
+			wantSignature: "xcodebuild: error: Unable to find a destination matching the provided destination specifier:",
+		},
+		{
+			name:          "package resolution",
+			xcodebuildLog: "Resolve Package Graph\nxcodebuild: error: Could not resolve package dependencies:\n  Failed to clone repository git@github.com:bitrise-io/private-package.git",
+			wantCategory:  failureCategorySPM,
+			wantSignature: "xcodebuild: error: Could not resolve package dependencies:",
+		},
+		{
+			name:          "scheme without test action",
+			xcodebuildLog: "xcodebuild: error: Failed to build workspace BullsEye with scheme BullsEye.\n\tReason: Scheme BullsEye is not currently configured for the test action.",
+			wantCategory:  failureCategoryScheme,
+			wantSignature: "Reason: Scheme BullsEye is not currently configured for the test action.",
+		},
+		{
+			name:          "compile error and the following linker error",
+			xcodebuildLog: "/Users/vagrant/git/BullsEye/ContentView.swift:42:17: error: cannot find 'score' in scope\nld: symbol(s) not found for architecture arm64",
+			wantCategory:  failureCategoryCompile,
+			wantSignature: "/Users/vagrant/git/BullsEye/ContentView.swift:42:17: error: cannot find 'score' in scope",
+		},
+		{
+			name:          "undefined symbols",
+			xcodebuildLog: "Undefined symbols for architecture arm64:\n  \"_OBJC_CLASS_$_GameScene\", referenced from:\nld: symbol(s) not found for architecture arm64\nclang: error: linker command failed with exit code 1 (use -v to see invocation)",
+			wantCategory:  failureCategoryLink,
+			wantSignature: "Undefined symbols for architecture arm64:",
+		},
+		{
+			name:          "full disk",
+			xcodebuildLog: "/Users/vagrant/git/BullsEye/ContentView.swift:1:1: error: unable to write file: No space left on device",
+			wantCategory:  failureCategoryInfra,
+			wantSignature: "/Users/vagrant/git/BullsEye/ContentView.swift:1:1: error: unable to write file: No space left on device",
+		},
+		{
+			name:          "unknown failure",
+			xcodebuildLog: "** TEST BUILD FAILED **",
+			wantCategory:  failureCategoryUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := classifyBuildFailure(tt.xcodebuildLog)
+
+			require.Equal(t, tt.wantCategory, failure.Category)
+			require.Equal(t, tt.wantSignature, failure.Signature)
+			require.NotEmpty(t, failure.Hint)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package step
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+)
+
+const (
+	firebaseTestLabZipPathEnvKey     = "BITRISE_FIREBASE_TEST_LAB_ZIP_PATH"
+	firebaseTestLabZipPathListEnvKey = "BITRISE_FIREBASE_TEST_LAB_ZIP_PATH_LIST"
+
+	// firebaseTestLabMaxZipSize is Test Lab's upload size limit of the XCTest zip.
+	firebaseTestLabMaxZipSize int64 = 4 << 30
+)
+
+// firebaseTestLabPackager creates an upload-ready zip for every xctestrun file in the layout Test Lab expects:
+// a single xctestrun file at the zip root, next to the device build products directory (Debug-iphoneos).
+type firebaseTestLabPackager struct {
+	builder XcodebuildBuilder
+}
+
+func (p firebaseTestLabPackager) Package(opts ExportOpts, outputDir string) (PackagedArtifacts, error) {
+	zipOpts := opts
+	zipOpts.ArchiveFormat = string(archiver.FormatZip)
+
+	zipPthByXctestrun := map[string]string{}
+	for _, xctestrunPth := range opts.XctestrunPths {
+		if destination := xctestrunDestination(xctestrunPth); !strings.HasPrefix(destination, deviceSDK) {
+			return nil, fmt.Errorf("%s was built for %s, Firebase Test Lab requires a device (%s) build", filepath.Base(xctestrunPth), destination, deviceSDK)
+		}
+
+		entries, err := p.builder.xctestrunProductEntries(xctestrunPth, opts.SYMRoot)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if productsDir := strings.Split(filepath.ToSlash(entry), "/")[0]; !strings.HasSuffix(productsDir, "-"+deviceSDK) {
+				return nil, fmt.Errorf("%s references %s, which is not a device (%s) build product", filepath.Base(xctestrunPth), entry, deviceSDK)
+			}
+		}
+		entries = append(entries, filepath.Base(xctestrunPth))
+
+		zipPth := filepath.Join(outputDir, strings.TrimSuffix(filepath.Base(xctestrunPth), xctestrunExt)+".zip")
+		if err := p.builder.archiveTestBundleEntries(opts.SYMRoot, entries, zipPth, zipOpts); err != nil {
+			return nil, err
+		}
+
+		info, err := os.Stat(zipPth)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > firebaseTestLabMaxZipSize {
+			return nil, fmt.Errorf("%s is %s, which exceeds Firebase Test Lab's %s limit", zipPth, formatBytes(info.Size()), formatBytes(firebaseTestLabMaxZipSize))
+		}
+
+		zipPthByXctestrun[xctestrunPth] = zipPth
+	}
+
+	return PackagedArtifacts{
+		firebaseTestLabZipPathListEnvKey: sortedValues(zipPthByXctestrun),
+		firebaseTestLabZipPathEnvKey:     {zipPthByXctestrun[opts.DefaultXctestrunPth]},
+	}, nil
+}
@@ -0,0 +1,80 @@
+package step
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenDeviceBuild_WhenFirebaseTestLabPackage_ThenZipsEveryXctestrunWithItsProducts(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.pathChecker.On("IsPathExists", mock.Anything).Return(true, nil)
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	outputDir := t.TempDir()
+	xctestrunPth := "/symroot/BullsEye_FullTests_iphoneos15.5-arm64.xctestrun"
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(deviceXctestrunContent(t), nil)
+
+	expectedZipPth := filepath.Join(outputDir, "BullsEye_FullTests_iphoneos15.5-arm64.zip")
+	expectedEntries := []string{
+		"Debug-iphoneos/BullsEye.app",
+		"Debug-iphoneos/BullsEyeUITests-Runner.app",
+		"BullsEye_FullTests_iphoneos15.5-arm64.xctestrun",
+	}
+	stepMocks.archiver.On("Archive", "/symroot", expectedEntries, expectedZipPth, archiver.Opts{Format: archiver.FormatZip, CompressionLevel: 6}).
+		Return(func(srcDir string, entries []string, dstPth string, opts archiver.Opts) error {
+			return os.WriteFile(dstPth, []byte("zip"), 0644)
+		})
+
+	opts := ExportOpts{
+		RunOut: RunOut{
+			SYMRoot:             "/symroot",
+			XctestrunPths:       []string{xctestrunPth},
+			DefaultXctestrunPth: xctestrunPth,
+		},
+		OutputDir:        outputDir,
+		CompressionLevel: 6,
+		ArchiveFormat:    "tar.zst",
+	}
+
+	// When
+	artifacts, err := firebaseTestLabPackager{builder: step}.Package(opts, outputDir)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, PackagedArtifacts{
+		firebaseTestLabZipPathListEnvKey: {expectedZipPth},
+		firebaseTestLabZipPathEnvKey:     {expectedZipPth},
+	}, artifacts)
+}
+
+func Test_GivenSimulatorBuild_WhenFirebaseTestLabPackage_ThenFails(t *testing.T) {
+	// Given
+	step, _ := createStepAndMocks()
+
+	opts := ExportOpts{
+		RunOut: RunOut{
+			SYMRoot:       "/symroot",
+			XctestrunPths: []string{"/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"},
+		},
+	}
+
+	// When
+	_, err := firebaseTestLabPackager{builder: step}.Package(opts, t.TempDir())
+
+	// Then
+	require.EqualError(t, err, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun was built for iphonesimulator15.5-arm64, Firebase Test Lab requires a device (iphoneos) build")
+}
+
+// deviceXctestrunContent returns the simulator fixture xctestrun rewritten to a device build.
+func deviceXctestrunContent(t *testing.T) []byte {
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	return bytes.ReplaceAll(data, []byte("-iphonesimulator"), []byte("-iphoneos"))
+}
@@ -0,0 +1,113 @@
+package step
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
+)
+
+const (
+	integrityCheckFail = "fail"
+	integrityCheckWarn = "warn"
+)
+
+type missingProduct struct {
+	Key  string
+	Path string
+}
+
+type testTargetIntegrity struct {
+	Xctestrun  string
+	TestTarget string
+	Missing    []missingProduct
+	// Unchecked is the reason the xctestrun file could not be checked (the report has no TestTarget in this case).
+	Unchecked string
+}
+
+// checkTestBundleIntegrity resolves every build product path referenced by the xctestrun files (TestHostPath, TestBundlePath,
+// UITargetAppPath and DependentProductPaths) against the build root (SYMROOT) and collects the ones missing from the test bundle.
+//
+// Only paths relative to the __TESTROOT__ or __TESTHOST__ placeholders are checked,
+// other paths (for example __PLATFORMS__ relative ones) are not part of the test bundle.
+// The xctestrun files which can not be parsed are reported as unchecked.
+func (b XcodebuildBuilder) checkTestBundleIntegrity(bundle testBundle) ([]testTargetIntegrity, error) {
+	var reports []testTargetIntegrity
+	for _, xctestrunPth := range bundle.XctestrunPths {
+		testRun, err := b.readXctestrun(xctestrunPth)
+		if err != nil {
+			reports = append(reports, testTargetIntegrity{
+				Xctestrun: filepath.Base(xctestrunPth),
+				Unchecked: err.Error(),
+			})
+			continue
+		}
+
+		for _, target := range testRun.TestTargets() {
+			report := testTargetIntegrity{
+				Xctestrun:  filepath.Base(xctestrunPth),
+				TestTarget: target.BlueprintName,
+			}
+
+			for _, productPath := range target.ProductPaths() {
+				if !isInTestBundle(productPath.Path) {
+					continue
+				}
+
+				resolvedPth := target.ResolvePath(productPath.Path, bundle.SYMRoot)
+				exists, err := b.pathChecker.IsPathExists(resolvedPth)
+				if err != nil {
+					return nil, fmt.Errorf("failed to check if %s exists: %w", resolvedPth, err)
+				}
+				if !exists {
+					report.Missing = append(report.Missing, missingProduct{Key: productPath.Key, Path: resolvedPth})
+				}
+			}
+
+			reports = append(reports, report)
+		}
+	}
+
+	return reports, nil
+}
+
+// isInTestBundle reports whether a product path referenced by an xctestrun file points into the test bundle.
+func isInTestBundle(pth string) bool {
+	return strings.HasPrefix(pth, xctestrun.TestRootPlaceholder) || strings.HasPrefix(pth, xctestrun.TestHostPlaceholder)
+}
+
+func (b XcodebuildBuilder) reportTestBundleIntegrity(reports []testTargetIntegrity, mode string) error {
+	b.logger.Println()
+	b.logger.Infof("Checking test bundle integrity")
+
+	missingCount := 0
+	for _, report := range reports {
+		if report.Unchecked != "" {
+			b.logger.Warnf("- %s: not checked, failed to read the xctestrun file: %s", report.Xctestrun, report.Unchecked)
+			continue
+		}
+		if len(report.Missing) == 0 {
+			b.logger.Printf("- %s (%s): all referenced products found", report.TestTarget, report.Xctestrun)
+			continue
+		}
+
+		missingCount += len(report.Missing)
+		b.logger.Warnf("- %s (%s): %d referenced product(s) missing", report.TestTarget, report.Xctestrun, len(report.Missing))
+		for _, missing := range report.Missing {
+			b.logger.Warnf("  %s: %s", missing.Key, missing.Path)
+		}
+	}
+
+	if missingCount == 0 {
+		b.logger.Donef("Every product referenced by the xctestrun file(s) is present in the test bundle")
+		return nil
+	}
+
+	if mode == integrityCheckFail {
+		return fmt.Errorf("test bundle is incomplete: %d product(s) referenced by the xctestrun file(s) are missing", missingCount)
+	}
+
+	b.logger.Warnf("Test bundle is incomplete: %d product(s) referenced by the xctestrun file(s) are missing", missingCount)
+	return nil
+}
@@ -0,0 +1,116 @@
+package step
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-xcode/profileutil"
+)
+
+const (
+	ipaPathListEnvKey = "BITRISE_IPA_PATH_LIST"
+
+	ipaExportNone     = "none"
+	ipaExportUnsigned = "unsigned"
+	ipaExportSigned   = "signed"
+
+	embeddedProfileFileName = "embedded.mobileprovision"
+)
+
+// findDeviceApps returns the top level apps of the device build products directories (for example Debug-iphoneos),
+// which are the apps under test and the UI test runners. Nested apps (for example watchOS apps) are part of their container app's ipa.
+func (b XcodebuildBuilder) findDeviceApps(symRoot string) ([]string, error) {
+	entries, err := b.fileManager.ReadDir(symRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SYMROOT entries: %w", err)
+	}
+
+	var appPths []string
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), "-"+deviceSDK) {
+			continue
+		}
+
+		productsDir := filepath.Join(symRoot, entry.Name())
+		products, err := b.fileManager.ReadDir(productsDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s entries: %w", productsDir, err)
+		}
+		for _, product := range products {
+			if filepath.Ext(product.Name()) == ".app" {
+				appPths = append(appPths, filepath.Join(productsDir, product.Name()))
+			}
+		}
+	}
+
+	sort.Strings(appPths)
+	return appPths, nil
+}
+
+// verifyEmbeddedProfile checks that the app embeds a valid provisioning profile, which allows installing it on test devices.
+func (b XcodebuildBuilder) verifyEmbeddedProfile(appPth string) error {
+	profilePth := filepath.Join(appPth, embeddedProfileFileName)
+	exists, err := b.pathChecker.IsPathExists(profilePth)
+	if err != nil {
+		return fmt.Errorf("failed to check if %s exists: %w", profilePth, err)
+	}
+	if !exists {
+		return fmt.Errorf("%s has no %s, make sure the app is signed for device testing", filepath.Base(appPth), embeddedProfileFileName)
+	}
+
+	profile, err := profileutil.NewProvisioningProfileInfoFromFile(profilePth)
+	if err != nil {
+		return fmt.Errorf("failed to read the provisioning profile of %s: %w", filepath.Base(appPth), err)
+	}
+	if err := profile.CheckValidity(); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(appPth), err)
+	}
+	if profile.ExportType.IsAppStore() {
+		return fmt.Errorf("%s is signed with an App Store distribution profile (%s), which can't be installed on test devices", filepath.Base(appPth), profile.Name)
+	}
+
+	b.logger.Printf("%s is signed with %s (%s), team: %s (%s), expires: %s", filepath.Base(appPth), profile.Name, profile.ExportType, profile.TeamName, profile.TeamID, profile.ExpirationDate)
+	return nil
+}
+
+// exportIPAs wraps every device app into an ipa in the output directory.
+// In signed mode every app has to embed a provisioning profile, which can be used for installing it on test devices.
+func (b XcodebuildBuilder) exportIPAs(opts ExportOpts) error {
+	b.logger.Println()
+	b.logger.Infof("Creating ipa files")
+
+	appPths, err := b.findDeviceApps(opts.SYMRoot)
+	if err != nil {
+		return err
+	}
+	if len(appPths) == 0 {
+		b.logger.Warnf("No device (%s) app found in %s, make sure the destination is a device (for example generic/platform=iOS)", deviceSDK, opts.SYMRoot)
+		return nil
+	}
+
+	var ipaPths []string
+	for _, appPth := range appPths {
+		if opts.IPAExport == ipaExportSigned {
+			if err := b.verifyEmbeddedProfile(appPth); err != nil {
+				return err
+			}
+		}
+
+		ipaPth := ipaPath(opts.OutputDir, appPth)
+		if err := b.createIPA(appPth, ipaPth, opts); err != nil {
+			return err
+		}
+		ipaPths = append(ipaPths, ipaPth)
+	}
+
+	value := strings.Join(ipaPths, "|")
+	if err := tools.ExportEnvironmentWithEnvman(ipaPathListEnvKey, value); err != nil {
+		return fmt.Errorf("failed to export %s: %w", ipaPathListEnvKey, err)
+	}
+	b.logger.Donef("The ipa files are available in %s env: %s", ipaPathListEnvKey, value)
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package step
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenDeviceAndSimulatorProducts_WhenFindDeviceApps_ThenReturnsTopLevelDeviceApps(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.fileManager.On("ReadDir", "/symroot").Return([]os.DirEntry{
+		createDirEntry("BullsEye_FullTests_iphoneos15.5-arm64.xctestrun"),
+		createDirEntry("Debug-iphoneos"),
+		createDirEntry("Debug-iphonesimulator"),
+	}, nil)
+	stepMocks.fileManager.On("ReadDir", "/symroot/Debug-iphoneos").Return([]os.DirEntry{
+		createDirEntry("BullsEyeUITests-Runner.app"),
+		createDirEntry("BullsEye.app"),
+		createDirEntry("BullsEye.app.dSYM"),
+		createDirEntry("BullsEye.swiftmodule"),
+	}, nil)
+
+	// When
+	appPths, err := step.findDeviceApps("/symroot")
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/symroot/Debug-iphoneos/BullsEye.app",
+		"/symroot/Debug-iphoneos/BullsEyeUITests-Runner.app",
+	}, appPths)
+	stepMocks.fileManager.AssertNotCalled(t, "ReadDir", "/symroot/Debug-iphonesimulator")
+}
+
+func Test_GivenAppWithoutProvisioningProfile_WhenExportSignedIPAs_ThenFails(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.fileManager.On("ReadDir", "/symroot").Return([]os.DirEntry{createDirEntry("Debug-iphoneos")}, nil)
+	stepMocks.fileManager.On("ReadDir", "/symroot/Debug-iphoneos").Return([]os.DirEntry{createDirEntry("BullsEye.app")}, nil)
+	stepMocks.pathChecker.On("IsPathExists", "/symroot/Debug-iphoneos/BullsEye.app/embedded.mobileprovision").Return(false, nil)
+
+	opts := ExportOpts{
+		RunOut:    RunOut{SYMRoot: "/symroot"},
+		OutputDir: "/output",
+		IPAExport: ipaExportSigned,
+	}
+
+	// When
+	err := step.exportIPAs(opts)
+
+	// Then
+	require.EqualError(t, err, "BullsEye.app has no embedded.mobileprovision, make sure the app is signed for device testing")
+	stepMocks.archiver.AssertNotCalled(t, "Archive", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_GivenNoDeviceApps_WhenExportIPAs_ThenSkipsWithWarning(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything, mock.Anything).Return()
+	stepMocks.fileManager.On("ReadDir", "/symroot").Return([]os.DirEntry{createDirEntry("Debug-iphonesimulator")}, nil)
+
+	opts := ExportOpts{
+		RunOut:    RunOut{SYMRoot: "/symroot"},
+		OutputDir: "/output",
+		IPAExport: ipaExportUnsigned,
+	}
+
+	// When
+	err := step.exportIPAs(opts)
+
+	// Then
+	require.NoError(t, err)
+	stepMocks.logger.AssertNumberOfCalls(t, "Warnf", 1)
+	stepMocks.archiver.AssertNotCalled(t, "Archive", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,203 @@
+package step
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/output"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xcbundle"
+)
+
+const (
+	testBundleManifestPathEnvKey = "BITRISE_TEST_BUNDLE_MANIFEST_PATH"
+	testBundleManifestBaseName   = "test-bundle-manifest.json"
+)
+
+// testBundleManifest describes the produced test bundle, product paths are relative to the test bundle directory (SYMROOT).
+type testBundleManifest struct {
+	Scheme          string                  `json:"scheme"`
+	Configuration   string                  `json:"configuration,omitempty"`
+	Destinations    []string                `json:"destinations"`
+	XcodeVersion    string                  `json:"xcode_version,omitempty"`
+	TestPlans       []manifestTestPlan      `json:"test_plans,omitempty"`
+	Xctestruns      []manifestXctestrun     `json:"xctestruns"`
+	XctestrunShards []string                `json:"xctestrun_shards,omitempty"`
+	Products        []manifestProduct       `json:"products"`
+	Archive         *manifestTestBundleFile `json:"archive,omitempty"`
+}
+
+type manifestTestPlan struct {
+	Name      string `json:"name"`
+	IsDefault bool   `json:"is_default"`
+}
+
+type manifestXctestrun struct {
+	Path        string               `json:"path"`
+	TestPlan    string               `json:"test_plan"`
+	Destination string               `json:"destination"`
+	TestTargets []manifestTestTarget `json:"test_targets"`
+}
+
+type manifestTestTarget struct {
+	Name           string `json:"name"`
+	IsUITestBundle bool   `json:"is_ui_test_bundle"`
+	TestHost       string `json:"test_host,omitempty"`
+	TestBundle     string `json:"test_bundle"`
+	UITargetApp    string `json:"ui_target_app,omitempty"`
+}
+
+type manifestProduct struct {
+	Path          string   `json:"path"`
+	BundleID      string   `json:"bundle_id,omitempty"`
+	Architectures []string `json:"architectures,omitempty"`
+}
+
+type manifestTestBundleFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// createTestBundleManifest collects the test plans and the xctestrun files (with their test targets) of the test bundle,
+// the host apps and test bundles referenced by the test targets, and the xctestrun shards.
+func (b XcodebuildBuilder) createTestBundleManifest(cfg Config, bundle testBundle, shardPths []string) (testBundleManifest, error) {
+	manifest := testBundleManifest{
+		Scheme:        cfg.Scheme,
+		Configuration: cfg.Configuration,
+		Destinations:  cfg.Destinations,
+		XcodeVersion:  cfg.XcodeVersion,
+		Xctestruns:    []manifestXctestrun{},
+		Products:      []manifestProduct{},
+	}
+
+	testPlanIsDefault := map[string]bool{}
+	productPths := map[string]bool{}
+	for testPlan, xctestrunPthByDestination := range bundle.XctestrunPthsByTestPlan {
+		for destination, xctestrunPth := range xctestrunPthByDestination {
+			manifestXctestrun := manifestXctestrun{
+				Path:        relativeToSYMRoot(bundle.SYMRoot, xctestrunPth),
+				TestPlan:    testPlan,
+				Destination: destination,
+				TestTargets: []manifestTestTarget{},
+			}
+
+			testRun, err := b.readXctestrun(xctestrunPth)
+			if err != nil {
+				b.logger.Warnf("Failed to read %s, its test targets are not listed in the manifest: %s", xctestrunPth, err)
+				manifest.Xctestruns = append(manifest.Xctestruns, manifestXctestrun)
+				continue
+			}
+
+			// xctestrun files generated without a test plan are mapped to the scheme (or file name), which is not a test plan
+			if testRun.TestPlan != nil && testRun.TestPlan.Name != "" {
+				testPlanIsDefault[testRun.TestPlan.Name] = testRun.TestPlan.IsDefault
+			}
+
+			for _, target := range testRun.TestTargets() {
+				resolve := func(pth string) string {
+					if pth == "" {
+						return ""
+					}
+					return relativeToSYMRoot(bundle.SYMRoot, target.ResolvePath(pth, bundle.SYMRoot))
+				}
+
+				manifestXctestrun.TestTargets = append(manifestXctestrun.TestTargets, manifestTestTarget{
+					Name:           target.BlueprintName,
+					IsUITestBundle: target.IsUITestBundle,
+					TestHost:       resolve(target.TestHostPath),
+					TestBundle:     resolve(target.TestBundlePath),
+					UITargetApp:    resolve(target.UITargetAppPath),
+				})
+
+				for _, pth := range []string{target.TestHostPath, target.TestBundlePath, target.UITargetAppPath} {
+					if isInTestBundle(pth) {
+						productPths[resolve(pth)] = true
+					}
+				}
+			}
+
+			manifest.Xctestruns = append(manifest.Xctestruns, manifestXctestrun)
+		}
+	}
+	for _, name := range sortedKeys(testPlanIsDefault) {
+		manifest.TestPlans = append(manifest.TestPlans, manifestTestPlan{Name: name, IsDefault: testPlanIsDefault[name]})
+	}
+	sort.Slice(manifest.Xctestruns, func(i, j int) bool {
+		return manifest.Xctestruns[i].Path < manifest.Xctestruns[j].Path
+	})
+
+	for _, shardPth := range shardPths {
+		manifest.XctestrunShards = append(manifest.XctestrunShards, relativeToSYMRoot(bundle.SYMRoot, shardPth))
+	}
+
+	for _, productPth := range sortedKeys(productPths) {
+		product := manifestProduct{Path: productPth}
+
+		productBundle, err := xcbundle.Open(filepath.Join(bundle.SYMRoot, productPth))
+		if err != nil {
+			b.logger.Warnf("Failed to read %s: %s", productPth, err)
+		} else {
+			product.BundleID = productBundle.Identifier
+			product.Architectures = productBundle.Architectures
+		}
+
+		manifest.Products = append(manifest.Products, product)
+	}
+
+	return manifest, nil
+}
+
+func (b XcodebuildBuilder) exportTestBundleManifest(outputDir string, manifest testBundleManifest, testBundleArchivePth, testBundleHash string) error {
+	if testBundleArchivePth != "" && testBundleHash != "" {
+		manifest.Archive = &manifestTestBundleFile{Path: testBundleArchivePth, SHA256: testBundleHash}
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode test bundle manifest: %w", err)
+	}
+
+	manifestPth := filepath.Join(outputDir, testBundleManifestBaseName)
+	if err := output.ExportOutputFileContent(string(content), manifestPth, testBundleManifestPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", testBundleManifestPathEnvKey, err)
+	}
+	b.logger.Donef("The test bundle manifest is available in %s env: %s", testBundleManifestPathEnvKey, manifestPth)
+
+	return nil
+}
+
+func relativeToSYMRoot(symRoot, pth string) string {
+	if rel, err := filepath.Rel(symRoot, pth); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return pth
+}
+
+func fileSHA256(pth string) (string, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to compute checksum of %s: %w", pth, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,213 @@
+package step
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+)
+
+const (
+	packagingProfileNone            = "none"
+	packagingProfileFirebaseTestLab = "firebase-test-lab"
+	packagingProfileBrowserStack    = "browserstack"
+	packagingProfileSauceLabs       = "sauce-labs"
+	packagingProfileAWSDeviceFarm   = "aws-device-farm"
+
+	deviceSDK = "iphoneos"
+)
+
+// PackagedArtifacts maps output env keys to the paths of the created device farm artifacts.
+type PackagedArtifacts map[string][]string
+
+// Packager creates the artifacts a device farm expects from the built test bundle.
+type Packager interface {
+	// Package writes the artifacts into outputDir and returns them by output env key.
+	Package(opts ExportOpts, outputDir string) (PackagedArtifacts, error)
+}
+
+// newPackager returns the Packager of the packaging profile.
+func (b XcodebuildBuilder) newPackager(profile string) (Packager, error) {
+	switch profile {
+	case packagingProfileFirebaseTestLab:
+		return firebaseTestLabPackager{builder: b}, nil
+	case packagingProfileBrowserStack:
+		return browserStackPackager{builder: b}, nil
+	case packagingProfileSauceLabs:
+		return sauceLabsPackager{builder: b}, nil
+	case packagingProfileAWSDeviceFarm:
+		return awsDeviceFarmPackager{builder: b}, nil
+	default:
+		return nil, fmt.Errorf("unknown packaging profile: %s", profile)
+	}
+}
+
+// validateDeviceDestination fails if every build destination is a simulator, device farms only run device builds.
+func validateDeviceDestination(profile string, destinations []string) error {
+	for _, destination := range destinations {
+		if !strings.Contains(strings.ToLower(destination), "simulator") {
+			return nil
+		}
+	}
+	return fmt.Errorf("the %s packaging profile requires a device destination (for example generic/platform=iOS), got: %s", profile, strings.Join(destinations, ", "))
+}
+
+// deviceXctestruns filters the xctestrun files to the ones built for devices,
+// the default xctestrun file falls back to the first device xctestrun file if it was built for a simulator.
+func deviceXctestruns(xctestrunPths []string, defaultXctestrunPth string) ([]string, string) {
+	var devicePths []string
+	for _, xctestrunPth := range xctestrunPths {
+		if strings.HasPrefix(xctestrunDestination(xctestrunPth), deviceSDK) {
+			devicePths = append(devicePths, xctestrunPth)
+		}
+	}
+	if len(devicePths) == 0 {
+		return xctestrunPths, defaultXctestrunPth
+	}
+	if !sliceutil.IsStringInSlice(defaultXctestrunPth, devicePths) {
+		defaultXctestrunPth = devicePths[0]
+	}
+	return devicePths, defaultXctestrunPth
+}
+
+func (b XcodebuildBuilder) exportPackagedTestBundle(opts ExportOpts) error {
+	packager, err := b.newPackager(opts.PackagingProfile)
+	if err != nil {
+		return err
+	}
+
+	b.logger.Println()
+	b.logger.Infof("Packaging test bundle for %s", opts.PackagingProfile)
+
+	outputDir := filepath.Join(opts.OutputDir, opts.PackagingProfile)
+	if err := os.MkdirAll(outputDir, 0777); err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputDir, err)
+	}
+
+	// multi destination builds contain simulator xctestrun files too, device farms only run the device ones
+	packagerOpts := opts
+	packagerOpts.XctestrunPths, packagerOpts.DefaultXctestrunPth = deviceXctestruns(opts.XctestrunPths, opts.DefaultXctestrunPth)
+	if len(packagerOpts.XctestrunPths) < len(opts.XctestrunPths) {
+		b.logger.Printf("Packaging the device xctestrun files: %s", strings.Join(packagerOpts.XctestrunPths, ", "))
+	}
+
+	artifacts, err := packager.Package(packagerOpts, outputDir)
+	if err != nil {
+		return err
+	}
+
+	envKeys := make([]string, 0, len(artifacts))
+	for envKey := range artifacts {
+		envKeys = append(envKeys, envKey)
+	}
+	sort.Strings(envKeys)
+
+	for _, envKey := range envKeys {
+		value := strings.Join(artifacts[envKey], "|")
+		if err := tools.ExportEnvironmentWithEnvman(envKey, value); err != nil {
+			return fmt.Errorf("failed to export %s: %w", envKey, err)
+		}
+		b.logger.Donef("The %s artifacts are available in %s env: %s", opts.PackagingProfile, envKey, value)
+	}
+
+	return nil
+}
+
+// testProducts are the build products of a test target, as absolute paths.
+type testProducts struct {
+	TestTarget     string
+	IsUITestBundle bool
+	// App is the app under test: the UI target app of UI tests, the test host of app hosted unit tests.
+	App string
+	// Runner is the UI test runner app (*-Runner.app), only set for UI tests.
+	Runner     string
+	TestBundle string
+}
+
+// collectTestProducts resolves the products of every test target of the xctestrun files.
+func (b XcodebuildBuilder) collectTestProducts(symRoot string, xctestrunPths []string) ([]testProducts, error) {
+	var products []testProducts
+	for _, xctestrunPth := range xctestrunPths {
+		if destination := xctestrunDestination(xctestrunPth); !strings.HasPrefix(destination, deviceSDK) {
+			return nil, fmt.Errorf("%s was built for %s, device farms require a device (%s) build", filepath.Base(xctestrunPth), destination, deviceSDK)
+		}
+
+		testRun, err := b.readXctestrun(xctestrunPth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", xctestrunPth, err)
+		}
+
+		for _, target := range testRun.TestTargets() {
+			resolve := func(pth string) string {
+				if pth == "" {
+					return ""
+				}
+				return target.ResolvePath(pth, symRoot)
+			}
+
+			product := testProducts{
+				TestTarget:     target.BlueprintName,
+				IsUITestBundle: target.IsUITestBundle,
+				TestBundle:     resolve(target.TestBundlePath),
+			}
+			if target.IsUITestBundle {
+				product.App = resolve(target.UITargetAppPath)
+				product.Runner = resolve(target.TestHostPath)
+			} else if isInTestBundle(target.TestHostPath) {
+				product.App = resolve(target.TestHostPath)
+			}
+
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+// createIPA wraps the app into an ipa (Payload/<name>.app) at ipaPth.
+func (b XcodebuildBuilder) createIPA(appPth, ipaPth string, opts ExportOpts) error {
+	b.logger.Printf("Creating %s from %s", filepath.Base(ipaPth), appPth)
+	return b.archiver.Archive(filepath.Dir(appPth), []string{filepath.Base(appPth)}, ipaPth, archiver.Opts{
+		Format:           archiver.FormatZip,
+		CompressionLevel: opts.CompressionLevel,
+		Reproducible:     opts.Reproducible,
+		Prefix:           "Payload/",
+	})
+}
+
+// zipProduct zips the product (with the product dir at the zip root) at zipPth.
+func (b XcodebuildBuilder) zipProduct(productPth, zipPth string, opts ExportOpts) error {
+	b.logger.Printf("Zipping %s into %s", productPth, filepath.Base(zipPth))
+	return b.archiver.Archive(filepath.Dir(productPth), []string{filepath.Base(productPth)}, zipPth, archiver.Opts{
+		Format:           archiver.FormatZip,
+		CompressionLevel: opts.CompressionLevel,
+		Reproducible:     opts.Reproducible,
+	})
+}
+
+// ipaPath returns the ipa path of the app in outputDir.
+func ipaPath(outputDir, appPth string) string {
+	return filepath.Join(outputDir, strings.TrimSuffix(filepath.Base(appPth), filepath.Ext(appPth))+".ipa")
+}
+
+// artifactCache creates every artifact only once, even if multiple test targets share it (for example the app under test).
+type artifactCache map[string]bool
+
+func (c artifactCache) create(pth string, create func() error) error {
+	if c[pth] {
+		return nil
+	}
+	if err := create(); err != nil {
+		return err
+	}
+	c[pth] = true
+	return nil
+}
+
+func (c artifactCache) sorted() []string {
+	return sortedKeys(c)
+}
@@ -0,0 +1,171 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateDeviceDestination(t *testing.T) {
+	tests := []struct {
+		name         string
+		destinations []string
+		wantErr      bool
+	}{
+		{name: "generic device", destinations: []string{"generic/platform=iOS"}, wantErr: false},
+		{name: "device", destinations: []string{"platform=iOS,id=00008030-001A2D3E3C38802E"}, wantErr: false},
+		{name: "simulator", destinations: []string{"platform=iOS Simulator,name=iPhone 15"}, wantErr: true},
+		{name: "generic simulator", destinations: []string{"generic/platform=iOS Simulator"}, wantErr: true},
+		{name: "simulator and device", destinations: []string{"generic/platform=iOS Simulator", "generic/platform=iOS"}, wantErr: false},
+		{name: "multiple simulators", destinations: []string{"generic/platform=iOS Simulator", "generic/platform=tvOS Simulator"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDeviceDestination(packagingProfileBrowserStack, tt.destinations)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_deviceXctestruns(t *testing.T) {
+	simulatorPth := "/symroot/BullsEye_iphonesimulator17.0-arm64.xctestrun"
+	devicePth := "/symroot/BullsEye_iphoneos17.0-arm64.xctestrun"
+	tvSimulatorPth := "/symroot/BullsEye_appletvsimulator17.0-arm64.xctestrun"
+
+	tests := []struct {
+		name                string
+		xctestrunPths       []string
+		defaultXctestrunPth string
+		wantPths            []string
+		wantDefault         string
+	}{
+		{
+			name:                "device xctestrun is kept",
+			xctestrunPths:       []string{simulatorPth, devicePth, tvSimulatorPth},
+			defaultXctestrunPth: simulatorPth,
+			wantPths:            []string{devicePth},
+			wantDefault:         devicePth,
+		},
+		{
+			name:                "simulator only build is not filtered",
+			xctestrunPths:       []string{simulatorPth, tvSimulatorPth},
+			defaultXctestrunPth: simulatorPth,
+			wantPths:            []string{simulatorPth, tvSimulatorPth},
+			wantDefault:         simulatorPth,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pths, defaultPth := deviceXctestruns(tt.xctestrunPths, tt.defaultXctestrunPth)
+			require.Equal(t, tt.wantPths, pths)
+			require.Equal(t, tt.wantDefault, defaultPth)
+		})
+	}
+}
+
+func Test_GivenDeviceBuild_WhenDeviceFarmPackage_ThenCreatesFarmSpecificArtifacts(t *testing.T) {
+	ipaOpts := archiver.Opts{Format: archiver.FormatZip, CompressionLevel: 6, Prefix: "Payload/"}
+	zipOpts := archiver.Opts{Format: archiver.FormatZip, CompressionLevel: 6}
+	appIPA := archiveCall{srcDir: "/symroot/Debug-iphoneos", entry: "BullsEye.app", dst: "/output/BullsEye.ipa", opts: ipaOpts}
+	runnerIPA := archiveCall{srcDir: "/symroot/Debug-iphoneos", entry: "BullsEyeUITests-Runner.app", dst: "/output/BullsEyeUITests-Runner.ipa", opts: ipaOpts}
+	runnerZip := archiveCall{srcDir: "/symroot/Debug-iphoneos", entry: "BullsEyeUITests-Runner.app", dst: "/output/BullsEyeUITests-Runner.app.zip", opts: zipOpts}
+	testBundleZip := archiveCall{srcDir: "/symroot/Debug-iphoneos/BullsEye.app/PlugIns", entry: "BullsEyeTests.xctest", dst: "/output/BullsEyeTests.xctest.zip", opts: zipOpts}
+
+	tests := []struct {
+		profile       string
+		wantArtifacts PackagedArtifacts
+		wantArchives  []archiveCall
+	}{
+		{
+			profile: packagingProfileBrowserStack,
+			wantArtifacts: PackagedArtifacts{
+				browserStackAppPathEnvKey:       {"/output/BullsEye.ipa"},
+				browserStackTestSuitePathEnvKey: {"/output/BullsEyeTests.xctest.zip", "/output/BullsEyeUITests-Runner.app.zip"},
+			},
+			wantArchives: []archiveCall{appIPA, testBundleZip, runnerZip},
+		},
+		{
+			profile: packagingProfileAWSDeviceFarm,
+			wantArtifacts: PackagedArtifacts{
+				awsDeviceFarmAppPathEnvKey:         {"/output/BullsEye.ipa"},
+				awsDeviceFarmTestPackagePathEnvKey: {"/output/BullsEyeTests.xctest.zip", "/output/BullsEyeUITests-Runner.ipa"},
+			},
+			wantArchives: []archiveCall{appIPA, testBundleZip, runnerIPA},
+		},
+		{
+			profile: packagingProfileSauceLabs,
+			wantArtifacts: PackagedArtifacts{
+				sauceLabsAppPathEnvKey:     {"/output/BullsEye.ipa"},
+				sauceLabsTestAppPathEnvKey: {"/output/BullsEyeUITests-Runner.ipa"},
+			},
+			wantArchives: []archiveCall{appIPA, runnerIPA},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			// Given
+			step, stepMocks := createStepAndMocks()
+			stepMocks.logger.On("Printf", mock.Anything, mock.Anything, mock.Anything).Return()
+			stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+
+			xctestrunPth := "/symroot/BullsEye_FullTests_iphoneos15.5-arm64.xctestrun"
+			stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(deviceXctestrunContent(t), nil)
+			stepMocks.archiver.On("Archive", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			packager, err := step.newPackager(tt.profile)
+			require.NoError(t, err)
+
+			opts := ExportOpts{
+				RunOut: RunOut{
+					SYMRoot:       "/symroot",
+					XctestrunPths: []string{xctestrunPth},
+				},
+				CompressionLevel: 6,
+			}
+
+			// When
+			artifacts, err := packager.Package(opts, "/output")
+
+			// Then
+			require.NoError(t, err)
+			require.Equal(t, tt.wantArtifacts, artifacts)
+			stepMocks.archiver.AssertNumberOfCalls(t, "Archive", len(tt.wantArchives))
+			for _, call := range tt.wantArchives {
+				stepMocks.archiver.AssertCalled(t, "Archive", call.srcDir, []string{call.entry}, call.dst, call.opts)
+			}
+		})
+	}
+}
+
+func Test_GivenSimulatorBuild_WhenDeviceFarmPackage_ThenFails(t *testing.T) {
+	// Given
+	step, _ := createStepAndMocks()
+	packager, err := step.newPackager(packagingProfileBrowserStack)
+	require.NoError(t, err)
+
+	opts := ExportOpts{
+		RunOut: RunOut{
+			SYMRoot:       "/symroot",
+			XctestrunPths: []string{"/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"},
+		},
+	}
+
+	// When
+	_, err = packager.Package(opts, "/output")
+
+	// Then
+	require.EqualError(t, err, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun was built for iphonesimulator15.5-arm64, device farms require a device (iphoneos) build")
+}
+
+type archiveCall struct {
+	srcDir string
+	entry  string
+	dst    string
+	opts   archiver.Opts
+}
@@ -0,0 +1,175 @@
+package step
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+)
+
+const (
+	packagingSingle         = "single"
+	packagingPerXctestrun   = "per_xctestrun"
+	packagingPerDestination = "per_destination"
+
+	archiveFormatNone = "none"
+)
+
+// xctestrunProductEntries returns the build products referenced by the xctestrun file, relative to the build root (SYMROOT).
+// Products nested into an other referenced product (for example a test bundle in the host app's PlugIns dir) are omitted.
+// Products missing from the build root are skipped with a warning, the integrity check decides whether they fail the step.
+func (b XcodebuildBuilder) xctestrunProductEntries(xctestrunPth, symRoot string) ([]string, error) {
+	testRun, err := b.readXctestrun(xctestrunPth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", xctestrunPth, err)
+	}
+
+	productPths := map[string]bool{}
+	for _, target := range testRun.TestTargets() {
+		for _, productPath := range target.ProductPaths() {
+			if !isInTestBundle(productPath.Path) {
+				continue
+			}
+			productPths[relativeToSYMRoot(symRoot, target.ResolvePath(productPath.Path, symRoot))] = true
+		}
+	}
+
+	var entries []string
+	for _, entry := range topLevelPaths(sortedKeys(productPths)) {
+		exists, err := b.pathChecker.IsPathExists(filepath.Join(symRoot, entry))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if %s exists: %w", entry, err)
+		}
+		if !exists {
+			b.logger.Warnf("%s references %s, which is missing from the test bundle, skipping it", filepath.Base(xctestrunPth), entry)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// topLevelPaths drops the paths nested into an other path of the sorted list.
+// A parent path sorts before its nested paths, but not necessarily right before them
+// (BullsEye.app.dSYM sorts between BullsEye.app and BullsEye.app/PlugIns), so every parent of a path is checked.
+func topLevelPaths(sortedPths []string) []string {
+	var pths []string
+	kept := map[string]bool{}
+	for _, pth := range sortedPths {
+		nested := false
+		for parent := filepath.Dir(pth); parent != filepath.Dir(parent); parent = filepath.Dir(parent) {
+			if kept[parent] {
+				nested = true
+				break
+			}
+		}
+		if nested {
+			continue
+		}
+		kept[pth] = true
+		pths = append(pths, pth)
+	}
+	return pths
+}
+
+// packagePerXctestrun creates a self-contained archive for every xctestrun file, containing the xctestrun file
+// and the build products it references.
+func (b XcodebuildBuilder) packagePerXctestrun(opts ExportOpts) (map[string]string, error) {
+	archivePthByXctestrun := map[string]string{}
+	for _, xctestrunPth := range opts.XctestrunPths {
+		var entries []string
+		if opts.Prune {
+			pruned, err := b.pruneTestBundle(opts.SYMRoot, []string{xctestrunPth}, opts.PruneAllowList, opts.PruneDenyList)
+			if err != nil {
+				return nil, fmt.Errorf("failed to prune test bundle: %w", err)
+			}
+			b.reportPrunedTestBundle(pruned)
+
+			entries = pruned.Entries
+		} else {
+			productEntries, err := b.xctestrunProductEntries(xctestrunPth, opts.SYMRoot)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(productEntries, filepath.Base(xctestrunPth))
+		}
+		// the shards reference the build products of the xctestrun file they are written for
+		entries = append(entries, xctestrunShardEntries(xctestrunShards(xctestrunPth, opts.XctestrunShardPths))...)
+
+		archiveExt := archiver.Format(opts.ArchiveFormat).Extension()
+		archivePth := filepath.Join(opts.OutputDir, strings.TrimSuffix(filepath.Base(xctestrunPth), xctestrunExt)+archiveExt)
+		if err := b.archiveTestBundleEntries(opts.SYMRoot, entries, archivePth, opts); err != nil {
+			return nil, err
+		}
+
+		archivePthByXctestrun[xctestrunPth] = archivePth
+	}
+	return archivePthByXctestrun, nil
+}
+
+// packagePerDestination creates a self-contained archive for every build destination (for example iphoneos17.0-arm64),
+// containing the xctestrun files built for the destination and the build products they reference.
+func (b XcodebuildBuilder) packagePerDestination(opts ExportOpts) (map[string]string, error) {
+	destinations := map[string]bool{}
+	xctestrunPthsByDestination := map[string][]string{}
+	for _, xctestrunPth := range opts.XctestrunPths {
+		destination := xctestrunDestination(xctestrunPth)
+		destinations[destination] = true
+		xctestrunPthsByDestination[destination] = append(xctestrunPthsByDestination[destination], xctestrunPth)
+	}
+
+	archivePthByDestination := map[string]string{}
+	for _, destination := range sortedKeys(destinations) {
+		xctestrunPths := xctestrunPthsByDestination[destination]
+
+		var entries []string
+		if opts.Prune {
+			pruned, err := b.pruneTestBundle(opts.SYMRoot, xctestrunPths, opts.PruneAllowList, opts.PruneDenyList)
+			if err != nil {
+				return nil, fmt.Errorf("failed to prune test bundle: %w", err)
+			}
+			b.reportPrunedTestBundle(pruned)
+
+			entries = pruned.Entries
+		} else {
+			productPths := map[string]bool{}
+			for _, xctestrunPth := range xctestrunPths {
+				productEntries, err := b.xctestrunProductEntries(xctestrunPth, opts.SYMRoot)
+				if err != nil {
+					return nil, err
+				}
+				for _, entry := range productEntries {
+					productPths[entry] = true
+				}
+			}
+			entries = topLevelPaths(sortedKeys(productPths))
+			for _, xctestrunPth := range xctestrunPths {
+				entries = append(entries, filepath.Base(xctestrunPth))
+			}
+		}
+		// the shards reference the build products of the xctestrun file they are written for
+		for _, xctestrunPth := range xctestrunPths {
+			entries = append(entries, xctestrunShardEntries(xctestrunShards(xctestrunPth, opts.XctestrunShardPths))...)
+		}
+
+		archivePth := filepath.Join(opts.OutputDir, "testbundle_"+destination+archiver.Format(opts.ArchiveFormat).Extension())
+		if err := b.archiveTestBundleEntries(opts.SYMRoot, entries, archivePth, opts); err != nil {
+			return nil, err
+		}
+
+		archivePthByDestination[destination] = archivePth
+	}
+	return archivePthByDestination, nil
+}
+
+func sortedValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,211 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_topLevelPaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		sortedPths []string
+		wantPths   []string
+	}{
+		{
+			name:       "empty",
+			sortedPths: nil,
+			wantPths:   nil,
+		},
+		{
+			name: "nested test bundle",
+			sortedPths: []string{
+				"Debug-iphonesimulator/BullsEye.app",
+				"Debug-iphonesimulator/BullsEye.app/PlugIns/BullsEyeTests.xctest",
+				"Debug-iphonesimulator/BullsEyeUITests-Runner.app",
+			},
+			wantPths: []string{
+				"Debug-iphonesimulator/BullsEye.app",
+				"Debug-iphonesimulator/BullsEyeUITests-Runner.app",
+			},
+		},
+		{
+			name: "common name prefix",
+			sortedPths: []string{
+				"Debug-iphonesimulator/BullsEye.app",
+				"Debug-iphonesimulator/BullsEye.app.dSYM",
+			},
+			wantPths: []string{
+				"Debug-iphonesimulator/BullsEye.app",
+				"Debug-iphonesimulator/BullsEye.app.dSYM",
+			},
+		},
+		{
+			name: "nested path sorted after a sibling with common name prefix",
+			sortedPths: []string{
+				"Debug-iphonesimulator/BullsEye.app",
+				"Debug-iphonesimulator/BullsEye.app.dSYM",
+				"Debug-iphonesimulator/BullsEye.app/PlugIns/BullsEyeWidget.appex",
+			},
+			wantPths: []string{
+				"Debug-iphonesimulator/BullsEye.app",
+				"Debug-iphonesimulator/BullsEye.app.dSYM",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topLevelPaths(tt.sortedPths); !reflect.DeepEqual(got, tt.wantPths) {
+				t.Errorf("topLevelPaths() = %v, want %v", got, tt.wantPths)
+			}
+		})
+	}
+}
+
+func Test_GivenXctestrun_WhenXctestrunProductEntries_ThenReturnsReferencedProducts(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.pathChecker.On("IsPathExists", mock.Anything).Return(true, nil)
+
+	xctestrunPth := "/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(data, nil)
+
+	// When
+	entries, err := step.xctestrunProductEntries(xctestrunPth, "/symroot")
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Debug-iphonesimulator/BullsEye.app",
+		"Debug-iphonesimulator/BullsEyeUITests-Runner.app",
+	}, entries)
+}
+
+func Test_GivenMissingProduct_WhenXctestrunProductEntries_ThenSkipsIt(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+	stepMocks.pathChecker.On("IsPathExists", "/symroot/Debug-iphonesimulator/BullsEye.app").Return(true, nil)
+	stepMocks.pathChecker.On("IsPathExists", "/symroot/Debug-iphonesimulator/BullsEyeUITests-Runner.app").Return(false, nil)
+
+	xctestrunPth := "/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(data, nil)
+
+	// When
+	entries, err := step.xctestrunProductEntries(xctestrunPth, "/symroot")
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{"Debug-iphonesimulator/BullsEye.app"}, entries)
+	stepMocks.logger.AssertCalled(t, "Warnf", mock.Anything, mock.Anything)
+}
+
+func Test_GivenXctestrun_WhenPackagePerXctestrun_ThenArchivesReferencedProducts(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.pathChecker.On("IsPathExists", mock.Anything).Return(true, nil)
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	xctestrunPth := "/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(data, nil)
+
+	expectedEntries := []string{
+		"Debug-iphonesimulator/BullsEye.app",
+		"Debug-iphonesimulator/BullsEyeUITests-Runner.app",
+		"BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun",
+		"BullsEye_FullTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun",
+		"BullsEye_FullTests_iphonesimulator15.5-arm64_shard-2-of-2.xctestrun",
+	}
+	expectedArchivePth := "/output/BullsEye_FullTests_iphonesimulator15.5-arm64.tar.zst"
+	stepMocks.archiver.On("Archive", "/symroot", expectedEntries, expectedArchivePth, archiver.Opts{Format: archiver.FormatTarZst, CompressionLevel: 6, Reproducible: true}).Return(nil)
+
+	opts := ExportOpts{
+		RunOut: RunOut{
+			SYMRoot:             "/symroot",
+			XctestrunPths:       []string{xctestrunPth},
+			DefaultXctestrunPth: xctestrunPth,
+			XctestrunShardPths: []string{
+				"/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun",
+				"/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64_shard-2-of-2.xctestrun",
+			},
+		},
+		OutputDir:        "/output",
+		CompressionLevel: 6,
+		Reproducible:     true,
+		ArchiveFormat:    "tar.zst",
+	}
+
+	// When
+	archivePthByXctestrun, err := step.packagePerXctestrun(opts)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{xctestrunPth: expectedArchivePth}, archivePthByXctestrun)
+	stepMocks.archiver.AssertExpectations(t)
+}
+
+func Test_GivenMultipleDestinations_WhenPackagePerDestination_ThenArchivesPerDestination(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.pathChecker.On("IsPathExists", mock.Anything).Return(true, nil)
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	simulatorFullTestsPth := "/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	simulatorUITestsPth := "/symroot/BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun"
+	deviceFullTestsPth := "/symroot/BullsEye_FullTests_iphoneos15.5-arm64.xctestrun"
+	stepMocks.fileManager.On("ReadFile", simulatorFullTestsPth).Return(data, nil)
+	stepMocks.fileManager.On("ReadFile", simulatorUITestsPth).Return(data, nil)
+	stepMocks.fileManager.On("ReadFile", deviceFullTestsPth).Return(deviceXctestrunContent(t), nil)
+
+	opts := ExportOpts{
+		RunOut: RunOut{
+			SYMRoot:             "/symroot",
+			XctestrunPths:       []string{simulatorFullTestsPth, deviceFullTestsPth, simulatorUITestsPth},
+			DefaultXctestrunPth: simulatorFullTestsPth,
+			XctestrunShardPths:  []string{"/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun"},
+		},
+		OutputDir:        "/output",
+		CompressionLevel: 6,
+		ArchiveFormat:    "zip",
+	}
+	archiveOpts := archiver.Opts{Format: archiver.FormatZip, CompressionLevel: 6}
+
+	simulatorEntries := []string{
+		"Debug-iphonesimulator/BullsEye.app",
+		"Debug-iphonesimulator/BullsEyeUITests-Runner.app",
+		"BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun",
+		"BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun",
+		"BullsEye_FullTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun",
+	}
+	stepMocks.archiver.On("Archive", "/symroot", simulatorEntries, "/output/testbundle_iphonesimulator15.5-arm64.zip", archiveOpts).Return(nil)
+	deviceEntries := []string{
+		"Debug-iphoneos/BullsEye.app",
+		"Debug-iphoneos/BullsEyeUITests-Runner.app",
+		"BullsEye_FullTests_iphoneos15.5-arm64.xctestrun",
+	}
+	stepMocks.archiver.On("Archive", "/symroot", deviceEntries, "/output/testbundle_iphoneos15.5-arm64.zip", archiveOpts).Return(nil)
+
+	// When
+	archivePthByDestination, err := step.packagePerDestination(opts)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"iphonesimulator15.5-arm64": "/output/testbundle_iphonesimulator15.5-arm64.zip",
+		"iphoneos15.5-arm64":        "/output/testbundle_iphoneos15.5-arm64.zip",
+	}, archivePthByDestination)
+	stepMocks.archiver.AssertExpectations(t)
+}
@@ -0,0 +1,167 @@
+package step
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+)
+
+// prunedTestBundle describes the test bundle content kept by the pruning pass.
+type prunedTestBundle struct {
+	// Entries are the kept build products and xctestrun files, relative to the build root (SYMROOT).
+	Entries []string
+	// Excludes are the deny list glob patterns, applied to every file of the kept entries.
+	Excludes     []string
+	TotalBytes   int64
+	KeptBytes    int64
+	DroppedFiles int
+}
+
+func (p prunedTestBundle) DroppedBytes() int64 {
+	return p.TotalBytes - p.KeptBytes
+}
+
+// pruneTestBundle keeps only the build products referenced by the xctestrun files, the paths matching the allow list
+// and the xctestrun files themselves. Every path matching the deny list is dropped, even if it is referenced.
+func (b XcodebuildBuilder) pruneTestBundle(symRoot string, xctestrunPths []string, allowList, denyList []string) (prunedTestBundle, error) {
+	keptPths := map[string]bool{}
+	for _, xctestrunPth := range xctestrunPths {
+		productEntries, err := b.xctestrunProductEntries(xctestrunPth, symRoot)
+		if err != nil {
+			return prunedTestBundle{}, err
+		}
+		for _, entry := range productEntries {
+			keptPths[entry] = true
+		}
+	}
+
+	allowedPths, err := matchingPaths(symRoot, allowList)
+	if err != nil {
+		return prunedTestBundle{}, err
+	}
+	for _, pth := range allowedPths {
+		keptPths[pth] = true
+	}
+
+	entries := topLevelPaths(sortedKeys(keptPths))
+	for _, xctestrunPth := range xctestrunPths {
+		entries = append(entries, filepath.Base(xctestrunPth))
+	}
+
+	pruned := prunedTestBundle{
+		Entries:  entries,
+		Excludes: denyList,
+	}
+	if err := pruned.measure(symRoot); err != nil {
+		return prunedTestBundle{}, err
+	}
+
+	return pruned, nil
+}
+
+// measure sums the size of every file in the build root and of the files kept by the pruning pass.
+func (p *prunedTestBundle) measure(symRoot string) error {
+	return filepath.WalkDir(symRoot, func(pth string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		p.TotalBytes += info.Size()
+
+		if p.isKept(relativeToSYMRoot(symRoot, pth)) {
+			p.KeptBytes += info.Size()
+		} else {
+			p.DroppedFiles++
+		}
+		return nil
+	})
+}
+
+// isKept reports whether the file (relative to the build root) is part of a kept entry and none of its path components are denied.
+func (p prunedTestBundle) isKept(rel string) bool {
+	name := filepath.ToSlash(rel)
+
+	for dir := name; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if archiver.Match(dir, p.Excludes) {
+			return false
+		}
+	}
+
+	for _, entry := range p.Entries {
+		if name == entry || strings.HasPrefix(name, entry+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingPaths returns the paths (relative to the build root) matching any of the glob patterns,
+// the content of a matching directory is not listed separately.
+func matchingPaths(symRoot string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	var pths []string
+	if err := filepath.WalkDir(symRoot, func(pth string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if pth == symRoot {
+			return nil
+		}
+
+		name := filepath.ToSlash(relativeToSYMRoot(symRoot, pth))
+		if !archiver.Match(name, patterns) {
+			return nil
+		}
+
+		pths = append(pths, name)
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", symRoot, err)
+	}
+
+	sort.Strings(pths)
+	return pths, nil
+}
+
+func (b XcodebuildBuilder) reportPrunedTestBundle(pruned prunedTestBundle) {
+	b.logger.Println()
+	b.logger.Infof("Pruning test bundle")
+	for _, entry := range pruned.Entries {
+		b.logger.Printf("- %s", entry)
+	}
+	if len(pruned.Excludes) > 0 {
+		b.logger.Printf("Excluded patterns: %s", strings.Join(pruned.Excludes, ", "))
+	}
+	b.logger.Donef("Kept %s of %s, dropped %s (%d files)", formatBytes(pruned.KeptBytes), formatBytes(pruned.TotalBytes), formatBytes(pruned.DroppedBytes()), pruned.DroppedFiles)
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
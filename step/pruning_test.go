@@ -0,0 +1,72 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenBuildProducts_WhenPruneTestBundle_ThenKeepsReferencedAndAllowedProducts(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.pathChecker.On("IsPathExists", mock.Anything).Return(true, nil)
+
+	symRoot := t.TempDir()
+	writeTestFile(t, filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.app", "BullsEye"), 100)
+	writeTestFile(t, filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.app", "PlugIns", "BullsEyeTests.xctest", "BullsEyeTests"), 20)
+	writeTestFile(t, filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.app", "BullsEye.swiftdoc"), 5)
+	writeTestFile(t, filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEyeUITests-Runner.app", "BullsEyeUITests-Runner"), 50)
+	writeTestFile(t, filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.swiftmodule", "arm64.swiftmodule"), 300)
+	writeTestFile(t, filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.app.dSYM", "Contents", "Info.plist"), 400)
+	writeTestFile(t, filepath.Join(symRoot, "Debug-iphonesimulator", "Intermediates", "BullsEye.o"), 1000)
+
+	xctestrunPth := filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(xctestrunPth, data, 0644))
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(data, nil)
+
+	// When
+	pruned, err := step.pruneTestBundle(symRoot, []string{xctestrunPth}, []string{"*.dSYM"}, []string{"*.swiftdoc"})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Debug-iphonesimulator/BullsEye.app",
+		"Debug-iphonesimulator/BullsEye.app.dSYM",
+		"Debug-iphonesimulator/BullsEyeUITests-Runner.app",
+		"BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun",
+	}, pruned.Entries)
+	require.Equal(t, []string{"*.swiftdoc"}, pruned.Excludes)
+
+	xctestrunSize := int64(len(data))
+	require.Equal(t, 1875+xctestrunSize, pruned.TotalBytes)
+	require.Equal(t, 570+xctestrunSize, pruned.KeptBytes)
+	require.Equal(t, int64(1305), pruned.DroppedBytes())
+	require.Equal(t, 3, pruned.DroppedFiles)
+}
+
+func Test_formatBytes(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{size: 0, want: "0 B"},
+		{size: 1023, want: "1023 B"},
+		{size: 1536, want: "1.5 KiB"},
+		{size: 1887436800, want: "1.8 GiB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			require.Equal(t, tt.want, formatBytes(tt.size))
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, pth string, size int) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+	require.NoError(t, os.WriteFile(pth, make([]byte, size), 0644))
+}
@@ -0,0 +1,129 @@
+package step
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// xcodebuildActions are the build actions of the xcodebuild command, they are not passed to the package resolution phase.
+var xcodebuildActions = map[string]bool{
+	"build":                 true,
+	"build-for-testing":     true,
+	"analyze":               true,
+	"archive":               true,
+	"test":                  true,
+	"test-without-building": true,
+	"docbuild":              true,
+	"installsrc":            true,
+	"install":               true,
+	"clean":                 true,
+}
+
+// testActionOptions are the xcodebuild options only valid with the test actions, they are not passed to the package resolution phase.
+// The value tells whether the option has a value.
+var testActionOptions = map[string]bool{
+	"-only-testing":                                   true,
+	"-skip-testing":                                   true,
+	"-testPlan":                                       true,
+	"-only-test-configuration":                        true,
+	"-skip-test-configuration":                        true,
+	"-test-iterations":                                true,
+	"-retry-tests-on-failure":                         false,
+	"-run-tests-until-failure":                        false,
+	"-testProductsPath":                               true,
+	"-resultBundlePath":                               true,
+	"-enableCodeCoverage":                             true,
+	"-test-timeouts-enabled":                          true,
+	"-parallel-testing-enabled":                       true,
+	"-parallel-testing-worker-count":                  true,
+	"-maximum-parallel-testing-workers":               true,
+	"-maximum-concurrent-test-device-destinations":    true,
+	"-maximum-concurrent-test-simulator-destinations": true,
+}
+
+// packageResolution configures the Swift package resolution phase run before the build.
+// The timeout is applied by the command factory of the package resolution runner (see NewTimeoutCommandFactory).
+type packageResolution struct {
+	// Timeout is the time limit of a resolution attempt, 0 means no limit.
+	Timeout     time.Duration
+	RetryPolicy retryPolicy
+}
+
+// resolvePackagesArgs returns the arguments of the xcodebuild -resolvePackageDependencies command,
+// built the same way as xcodebuild.ResolvePackagesCommandModel does (the model only exposes running the command,
+// without a timeout or the command output).
+// Every xcodebuild option is passed to the resolution, so that it resolves the packages the same way the build does,
+// except for the build actions and the test action options, which are returned as dropped options.
+func resolvePackagesArgs(projectPath, scheme, configuration string, xcodebuildOptions []string) ([]string, []string) {
+	var args []string
+	if filepath.Ext(projectPath) == ".xcworkspace" {
+		args = append(args, "-workspace", projectPath)
+	} else {
+		args = append(args, "-project", projectPath)
+	}
+	args = append(args, "-scheme", scheme)
+	if configuration != "" {
+		args = append(args, "-configuration", configuration)
+	}
+	args = append(args, "-resolvePackageDependencies")
+
+	var dropped []string
+	for i := 0; i < len(xcodebuildOptions); i++ {
+		option := xcodebuildOptions[i]
+		if xcodebuildActions[option] {
+			dropped = append(dropped, option)
+			continue
+		}
+		if hasValue, ok := testActionOptions[option]; ok {
+			dropped = append(dropped, option)
+			if hasValue && i+1 < len(xcodebuildOptions) {
+				i++
+				dropped = append(dropped, xcodebuildOptions[i])
+			}
+			continue
+		}
+		args = append(args, option)
+	}
+
+	return args, dropped
+}
+
+// resolvePackages resolves the Swift package dependencies before the build, so that a slow or unavailable package host
+// fails the Step with a Swift package specific error instead of eating up the build time.
+func (b XcodebuildBuilder) resolvePackages(cfg Config) (string, error) {
+	b.logger.Println()
+	b.logger.Infof("Resolving Swift package dependencies")
+
+	args, dropped := resolvePackagesArgs(cfg.ProjectPath, cfg.Scheme, cfg.Configuration, cfg.XcodebuildOptions)
+	if len(dropped) > 0 {
+		b.logger.Printf("Additional options not passed to the package resolution (build actions and test options): %s", strings.Join(dropped, " "))
+	}
+	if cfg.PackageResolution.Timeout > 0 {
+		b.logger.Printf("Timeout: %s", cfg.PackageResolution.Timeout)
+	}
+
+	paths := retryPaths{SwiftPackagesPath: cfg.SwiftPackagesPath}
+	if cfg.SwiftPackagesPath != "" {
+		paths.DerivedDataPath = filepath.Dir(cfg.SwiftPackagesPath)
+	}
+
+	startTime := time.Now()
+	output, err := runWithRetry(func() (string, error) {
+		output, err := b.packageResolutionRunner.Run("", args, nil)
+		if err != nil {
+			printLastLinesOfXcodebuildLog(b.logger, string(output.RawOut), false)
+		}
+		return string(output.RawOut), err
+	}, cfg.PackageResolution.RetryPolicy, paths, b.sleeper, b.logger)
+	duration := time.Since(startTime).Round(time.Second)
+
+	if err != nil {
+		b.logger.Println()
+		b.logger.Errorf("Failed to resolve Swift package dependencies in %s", duration)
+		return output, err
+	}
+	b.logger.Donef("Resolved Swift package dependencies in %s", duration)
+
+	return output, nil
+}
@@ -0,0 +1,106 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolvePackagesArgs(t *testing.T) {
+	tests := []struct {
+		name              string
+		projectPath       string
+		configuration     string
+		xcodebuildOptions []string
+		want              []string
+		wantDropped       []string
+	}{
+		{
+			name:        "project",
+			projectPath: "/BullsEye/BullsEye.xcodeproj",
+			want:        []string{"-project", "/BullsEye/BullsEye.xcodeproj", "-scheme", "BullsEye", "-resolvePackageDependencies"},
+		},
+		{
+			name:              "workspace with options",
+			projectPath:       "/BullsEye/BullsEye.xcworkspace",
+			configuration:     "Debug",
+			xcodebuildOptions: []string{"-clonedSourcePackagesDirPath", "/spm", "-onlyUsePackageVersionsFromResolvedFile", "-xcconfig", "/BullsEye/CI.xcconfig", "COMPILER_INDEX_STORE_ENABLE=NO"},
+			want:              []string{"-workspace", "/BullsEye/BullsEye.xcworkspace", "-scheme", "BullsEye", "-configuration", "Debug", "-resolvePackageDependencies", "-clonedSourcePackagesDirPath", "/spm", "-onlyUsePackageVersionsFromResolvedFile", "-xcconfig", "/BullsEye/CI.xcconfig", "COMPILER_INDEX_STORE_ENABLE=NO"},
+		},
+		{
+			name:              "build actions and test options",
+			projectPath:       "/BullsEye/BullsEye.xcodeproj",
+			xcodebuildOptions: []string{"clean", "-testPlan", "FullTests", "-skipPackagePluginValidation", "-retry-tests-on-failure", "-only-testing", "BullsEyeTests"},
+			want:              []string{"-project", "/BullsEye/BullsEye.xcodeproj", "-scheme", "BullsEye", "-resolvePackageDependencies", "-skipPackagePluginValidation"},
+			wantDropped:       []string{"clean", "-testPlan", "FullTests", "-retry-tests-on-failure", "-only-testing", "BullsEyeTests"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped := resolvePackagesArgs(tt.projectPath, "BullsEye", tt.configuration, tt.xcodebuildOptions)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantDropped, dropped)
+		})
+	}
+}
+
+func Test_GivenResolutionTimesOut_WhenResolvePackages_ThenResolutionIsNotRetried(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	mockLoggerCalls(stepMocks.logger)
+
+	args := []string{"-project", "/BullsEye/BullsEye.xcodeproj", "-scheme", "BullsEye", "-resolvePackageDependencies"}
+	stepMocks.packageResolutionRunner.On("Run", "", args, []string(nil)).Return(xcodecommand.Output{RawOut: []byte("Fetching from https://github.com/BullsEye/Scoring.git\n")}, fmt.Errorf("command timed out after 5m0s: %w", context.DeadlineExceeded))
+
+	cfg := Config{
+		ProjectPath:     "/BullsEye/BullsEye.xcodeproj",
+		Scheme:          "BullsEye",
+		ResolvePackages: true,
+		PackageResolution: packageResolution{
+			Timeout:     5 * time.Minute,
+			RetryPolicy: retryPolicy{MaxAttempts: 3, Backoff: time.Second, Rules: defaultRetryRules},
+		},
+	}
+
+	// When
+	output, err := step.resolvePackages(cfg)
+
+	// Then
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, "Fetching from https://github.com/BullsEye/Scoring.git\n", output)
+	stepMocks.packageResolutionRunner.AssertNumberOfCalls(t, "Run", 1)
+	stepMocks.sleeper.AssertNotCalled(t, "Sleep", mock.Anything)
+}
+
+func Test_GivenUnreachablePackageHost_WhenRun_ThenFailsWithSwiftPackageFailure(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	mockLoggerCalls(stepMocks.logger)
+
+	resolutionLog := "xcodebuild: error: Could not resolve package dependencies:\n  Failed to clone repository git@github.com:BullsEye/Scoring.git:\n    fatal: Could not read from remote repository.\n"
+	stepMocks.packageResolutionRunner.On("Run", "", mock.Anything, []string(nil)).Return(xcodecommand.Output{RawOut: []byte(resolutionLog), ExitCode: 74}, errors.New("exit status 74"))
+
+	cfg := Config{
+		ProjectPath:       "/BullsEye/BullsEye.xcodeproj",
+		Scheme:            "BullsEye",
+		ResolvePackages:   true,
+		PackageResolution: packageResolution{RetryPolicy: retryPolicy{MaxAttempts: 1}},
+	}
+
+	// When
+	result, err := step.Run(cfg)
+
+	// Then
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to resolve Swift package dependencies")
+	require.Equal(t, resolutionLog, result.XcodebuildLog)
+	require.NotNil(t, result.BuildFailure)
+	require.Equal(t, failureCategorySPM, result.BuildFailure.Category)
+	stepMocks.xcodeproject.AssertNotCalled(t, "Scheme", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,196 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	cache "github.com/bitrise-io/go-xcode/xcodecache"
+)
+
+// retryCleanup is an action run before retrying the build, to get rid of the state causing the failure.
+type retryCleanup string
+
+const (
+	retryCleanupNone          retryCleanup = "none"
+	retryCleanupSwiftPackages retryCleanup = "swift_packages"
+	retryCleanupDerivedData   retryCleanup = "derived_data"
+)
+
+// retryRule is a transient xcodebuild failure, recognized by a line of the xcodebuild log.
+type retryRule struct {
+	Pattern *regexp.Regexp
+	Cleanup retryCleanup
+	// Immediate rules are retried without waiting for the backoff of the retry policy.
+	Immediate bool
+}
+
+// defaultRetryRules are the known transient failures, retried with every retry policy.
+// The rules are matched in order, the first matching rule decides the cleanup action and the backoff.
+var defaultRetryRules = []retryRule{
+	{
+		// The network errors of the package resolution are printed below the generic package resolution failure
+		// (cache.SwiftPackagesStateInvalid), so they are matched first: they are retried with backoff, without removing the checkouts.
+		Pattern: regexp.MustCompile(`(?m)^.*(Failed to clone repository .*(timed out|Timed out)|The request timed out|Couldn't fetch updates from remote repositories|fatal: unable to access '.*': (Operation timed out|Failed to connect|Could not resolve host)).*$`),
+		Cleanup: retryCleanupNone,
+	},
+	{
+		// The Swift package checkouts or the resolved state in DerivedData is corrupted,
+		// the retry does not need to wait once the checkouts are removed.
+		Pattern:   regexp.MustCompile(`(?m)^.*` + regexp.QuoteMeta(cache.SwiftPackagesStateInvalid) + `.*$`),
+		Cleanup:   retryCleanupSwiftPackages,
+		Immediate: true,
+	},
+	{
+		Pattern: regexp.MustCompile(`(?m)^.*(Unable to boot the Simulator|Unable to boot device in current state).*$`),
+		Cleanup: retryCleanupNone,
+	},
+	{
+		Pattern: regexp.MustCompile(`(?m)^.*IDEDistribution.*(timed out|Timed out|did not respond).*$`),
+		Cleanup: retryCleanupNone,
+	},
+}
+
+// retryPolicy describes which failed builds are retried, how many times and how long to wait between the attempts.
+type retryPolicy struct {
+	// MaxAttempts is the number of builds run at most, including the first one.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, it is doubled for every further retry.
+	Backoff time.Duration
+	Rules   []retryRule
+}
+
+// retryPaths are the paths removed by the cleanup actions, and the result bundle which is removed before every retry
+// (xcodebuild fails if the result bundle already exists).
+type retryPaths struct {
+	SwiftPackagesPath string
+	DerivedDataPath   string
+	ResultBundlePath  string
+}
+
+// Sleeper waits between the build attempts.
+type Sleeper interface {
+	Sleep(d time.Duration)
+}
+
+type sleeper struct {
+}
+
+func NewSleeper() Sleeper {
+	return sleeper{}
+}
+
+func (s sleeper) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// parseRetryRules parses the custom retry rules, one `cleanup: pattern` item per line.
+func parseRetryRules(value string) ([]retryRule, error) {
+	var rules []retryRule
+	for _, line := range parseLines(value) {
+		cleanup, pattern, ok := strings.Cut(line, ":")
+		cleanup, pattern = strings.TrimSpace(cleanup), strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid retry signature (%s), use the `cleanup: pattern` format", line)
+		}
+
+		switch retryCleanup(cleanup) {
+		case retryCleanupNone, retryCleanupSwiftPackages, retryCleanupDerivedData:
+		default:
+			return nil, fmt.Errorf("invalid cleanup action (%s) of retry signature (%s), available actions: %s, %s, %s", cleanup, line, retryCleanupNone, retryCleanupSwiftPackages, retryCleanupDerivedData)
+		}
+
+		expression, err := regexp.Compile(`(?m)^.*(` + pattern + `).*$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of retry signature (%s): %w", line, err)
+		}
+		rules = append(rules, retryRule{Pattern: expression, Cleanup: retryCleanup(cleanup)})
+	}
+	return rules, nil
+}
+
+// match returns the first rule matching the xcodebuild log, and the matching log line.
+func (p retryPolicy) match(xcodebuildLog string) (retryRule, string, bool) {
+	for _, rule := range p.Rules {
+		if line := rule.Pattern.FindString(xcodebuildLog); line != "" {
+			return rule, strings.TrimSpace(line), true
+		}
+	}
+	return retryRule{}, "", false
+}
+
+// delay returns the wait before the given retry (1 for the first retry).
+func (p retryPolicy) delay(retry int) time.Duration {
+	return p.Backoff * time.Duration(1<<(retry-1))
+}
+
+// runWithRetry runs the xcodebuild command, and reruns it as long as it fails with a transient failure of the retry policy.
+// An attempt running into its timeout (failing with context.DeadlineExceeded) is not retried, so that a hanging command fails fast.
+// A failure is not retried if the path to remove by its cleanup action is unknown.
+func runWithRetry(run func() (string, error), policy retryPolicy, paths retryPaths, sleeper Sleeper, logger log.Logger) (string, error) {
+	for attempt := 1; ; attempt++ {
+		output, err := run()
+		if err == nil || attempt >= policy.MaxAttempts {
+			return output, err
+		}
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			return output, err
+		}
+
+		rule, line, ok := policy.match(output)
+		if !ok {
+			return output, err
+		}
+
+		logger.Println()
+		if rule.Cleanup != retryCleanupNone && paths.cleanupPath(rule.Cleanup) == "" {
+			logger.Warnf("Attempt %d/%d failed with a transient failure: %s, not retrying as the path to remove by the %s cleanup is unknown", attempt, policy.MaxAttempts, line, rule.Cleanup)
+			return output, err
+		}
+
+		logger.Warnf("Attempt %d/%d failed with a transient failure: %s", attempt, policy.MaxAttempts, line)
+		if err := runRetryCleanup(rule.Cleanup, paths, logger); err != nil {
+			return output, err
+		}
+
+		if delay := policy.delay(attempt); delay > 0 && !rule.Immediate {
+			logger.Printf("Retrying in %s", delay)
+			sleeper.Sleep(delay)
+		}
+		logger.Infof("Retrying")
+	}
+}
+
+// cleanupPath returns the path removed by the cleanup action, or an empty string if the path is unknown.
+func (p retryPaths) cleanupPath(cleanup retryCleanup) string {
+	switch cleanup {
+	case retryCleanupSwiftPackages:
+		return p.SwiftPackagesPath
+	case retryCleanupDerivedData:
+		return p.DerivedDataPath
+	}
+	return ""
+}
+
+func runRetryCleanup(cleanup retryCleanup, paths retryPaths, logger log.Logger) error {
+	if pth := paths.cleanupPath(cleanup); pth != "" {
+		logger.Printf("Removing %s", pth)
+		if err := os.RemoveAll(pth); err != nil {
+			return fmt.Errorf("failed to remove %s before retrying: %w", pth, err)
+		}
+	}
+
+	if paths.ResultBundlePath != "" {
+		if err := os.RemoveAll(paths.ResultBundlePath); err != nil {
+			return fmt.Errorf("failed to remove the result bundle of the failed build: %w", err)
+		}
+	}
+
+	return nil
+}
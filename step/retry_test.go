@@ -0,0 +1,204 @@
+package step
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
+	"github.com/bitrise-io/go-xcode/xcodebuild"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseRetryRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantCleanup []retryCleanup
+		wantErr     bool
+	}{
+		{
+			name:        "cleanup and pattern",
+			value:       "derived_data: accessing build database .* disk I/O error\nnone: Lost connection to the testmanagerd service",
+			wantCleanup: []retryCleanup{retryCleanupDerivedData, retryCleanupNone},
+		},
+		{
+			name:        "pattern with colon",
+			value:       "swift_packages: fatal: unable to access",
+			wantCleanup: []retryCleanup{retryCleanupSwiftPackages},
+		},
+		{
+			name:    "missing cleanup",
+			value:   "Lost connection to the testmanagerd service",
+			wantErr: true,
+		},
+		{
+			name:    "unknown cleanup",
+			value:   "simulators: Unable to boot the Simulator",
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			value:   "none: Lost connection (",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRetryRules(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var cleanups []retryCleanup
+			for _, rule := range got {
+				cleanups = append(cleanups, rule.Cleanup)
+			}
+			require.Equal(t, tt.wantCleanup, cleanups)
+		})
+	}
+}
+
+func Test_GivenInvalidSwiftPackagesState_WhenRunCommandWithRetry_ThenBuildIsRetriedOnceImmediatelyAfterCleanup(t *testing.T) {
+	// Given
+	logger := new(mocks.Logger)
+	mockLoggerCalls(logger)
+	sleeper := new(mocks.Sleeper)
+
+	derivedDataPth := t.TempDir()
+	paths := retryPaths{
+		SwiftPackagesPath: filepath.Join(derivedDataPth, "SourcePackages"),
+		DerivedDataPath:   derivedDataPth,
+		ResultBundlePath:  filepath.Join(t.TempDir(), xcresultBaseName),
+	}
+	require.NoError(t, os.MkdirAll(paths.SwiftPackagesPath, 0755))
+	require.NoError(t, os.MkdirAll(paths.ResultBundlePath, 0755))
+
+	runner := new(mocks.XCCommandRunner)
+	runner.On("Run", "", mock.Anything, []string{}).Return(xcodecommand.Output{RawOut: []byte("xcodebuild: error: Could not resolve package dependencies:\n  Package.resolved is out of date"), ExitCode: 74}, errors.New("exit status 74")).Once()
+	runner.On("Run", "", mock.Anything, []string{}).Return(xcodecommand.Output{RawOut: []byte("** TEST BUILD SUCCEEDED **")}, nil).Once()
+
+	// the retry_max_attempts and retry_backoff input defaults
+	policy := retryPolicy{MaxAttempts: 2, Backoff: 10 * time.Second, Rules: defaultRetryRules}
+
+	// When
+	output, err := runCommandWithRetry(runner, XcodebuildTool, xcodebuild.NewCommandBuilder("BullsEye.xcodeproj"), policy, paths, sleeper, logger)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, "** TEST BUILD SUCCEEDED **", output)
+	runner.AssertNumberOfCalls(t, "Run", 2)
+	sleeper.AssertNotCalled(t, "Sleep", mock.Anything)
+	require.NoDirExists(t, paths.SwiftPackagesPath)
+	require.NoDirExists(t, paths.ResultBundlePath)
+	require.DirExists(t, paths.DerivedDataPath)
+}
+
+func Test_GivenPackageFetchTimeout_WhenRunCommandWithRetry_ThenBuildIsRetriedWithBackoffWithoutCleanup(t *testing.T) {
+	// Given
+	logger := new(mocks.Logger)
+	mockLoggerCalls(logger)
+	sleeper := new(mocks.Sleeper)
+	sleeper.On("Sleep", 10*time.Second).Return().Once()
+
+	derivedDataPth := t.TempDir()
+	paths := retryPaths{
+		SwiftPackagesPath: filepath.Join(derivedDataPth, "SourcePackages"),
+		DerivedDataPath:   derivedDataPth,
+	}
+	require.NoError(t, os.MkdirAll(paths.SwiftPackagesPath, 0755))
+
+	runner := new(mocks.XCCommandRunner)
+	runner.On("Run", "", mock.Anything, []string{}).Return(xcodecommand.Output{RawOut: []byte("xcodebuild: error: Could not resolve package dependencies:\n  Failed to clone repository https://github.com/Alamofire/Alamofire.git:\n    fatal: unable to access 'https://github.com/Alamofire/Alamofire.git/': Operation timed out"), ExitCode: 74}, errors.New("exit status 74")).Once()
+	runner.On("Run", "", mock.Anything, []string{}).Return(xcodecommand.Output{RawOut: []byte("** TEST BUILD SUCCEEDED **")}, nil).Once()
+
+	policy := retryPolicy{MaxAttempts: 2, Backoff: 10 * time.Second, Rules: defaultRetryRules}
+
+	// When
+	_, err := runCommandWithRetry(runner, XcodebuildTool, xcodebuild.NewCommandBuilder("BullsEye.xcodeproj"), policy, paths, sleeper, logger)
+
+	// Then
+	require.NoError(t, err)
+	runner.AssertNumberOfCalls(t, "Run", 2)
+	sleeper.AssertExpectations(t)
+	require.DirExists(t, paths.SwiftPackagesPath)
+}
+
+func Test_GivenInvalidSwiftPackagesStateWithUnknownPath_WhenRunCommandWithRetry_ThenBuildIsNotRetried(t *testing.T) {
+	// Given
+	logger := new(mocks.Logger)
+	mockLoggerCalls(logger)
+	sleeper := new(mocks.Sleeper)
+
+	runner := new(mocks.XCCommandRunner)
+	runner.On("Run", "", mock.Anything, []string{}).Return(xcodecommand.Output{RawOut: []byte("xcodebuild: error: Could not resolve package dependencies:\n  Package.resolved is out of date"), ExitCode: 74}, errors.New("exit status 74"))
+
+	policy := retryPolicy{MaxAttempts: 2, Backoff: 10 * time.Second, Rules: defaultRetryRules}
+
+	// When
+	_, err := runCommandWithRetry(runner, XcodebuildTool, xcodebuild.NewCommandBuilder("BullsEye.xcodeproj"), policy, retryPaths{}, sleeper, logger)
+
+	// Then
+	require.Error(t, err)
+	runner.AssertNumberOfCalls(t, "Run", 1)
+	sleeper.AssertNotCalled(t, "Sleep", mock.Anything)
+}
+
+func Test_GivenPersistentTransientFailure_WhenRunCommandWithRetry_ThenStopsAfterMaxAttempts(t *testing.T) {
+	// Given
+	logger := new(mocks.Logger)
+	mockLoggerCalls(logger)
+	sleeper := new(mocks.Sleeper)
+	sleeper.On("Sleep", 5*time.Second).Return().Once()
+	sleeper.On("Sleep", 10*time.Second).Return().Once()
+
+	runner := new(mocks.XCCommandRunner)
+	runner.On("Run", "", mock.Anything, []string{}).Return(xcodecommand.Output{RawOut: []byte("Unable to boot the Simulator."), ExitCode: 65}, errors.New("exit status 65"))
+
+	policy := retryPolicy{MaxAttempts: 3, Backoff: 5 * time.Second, Rules: defaultRetryRules}
+
+	// When
+	_, err := runCommandWithRetry(runner, XcodebuildTool, xcodebuild.NewCommandBuilder("BullsEye.xcodeproj"), policy, retryPaths{}, sleeper, logger)
+
+	// Then
+	require.Error(t, err)
+	runner.AssertNumberOfCalls(t, "Run", 3)
+	sleeper.AssertExpectations(t)
+}
+
+func Test_GivenUnknownFailure_WhenRunCommandWithRetry_ThenBuildIsNotRetried(t *testing.T) {
+	// Given
+	logger := new(mocks.Logger)
+	mockLoggerCalls(logger)
+	sleeper := new(mocks.Sleeper)
+
+	runner := new(mocks.XCCommandRunner)
+	runner.On("Run", "", mock.Anything, []string{}).Return(xcodecommand.Output{RawOut: []byte("ContentView.swift:42:17: error: cannot find 'score' in scope"), ExitCode: 65}, errors.New("exit status 65"))
+
+	customRules, err := parseRetryRules("derived_data: accessing build database .* disk I/O error")
+	require.NoError(t, err)
+	policy := retryPolicy{MaxAttempts: 3, Rules: append(append([]retryRule{}, defaultRetryRules...), customRules...)}
+
+	// When
+	_, err = runCommandWithRetry(runner, XcodebuildTool, xcodebuild.NewCommandBuilder("BullsEye.xcodeproj"), policy, retryPaths{}, sleeper, logger)
+
+	// Then
+	require.Error(t, err)
+	runner.AssertNumberOfCalls(t, "Run", 1)
+	sleeper.AssertNotCalled(t, "Sleep", mock.Anything)
+}
+
+// mockLoggerCalls accepts the logger calls of the retried commands, with and without format arguments.
+func mockLoggerCalls(logger *mocks.Logger) {
+	logger.On("Println").Return()
+	for _, method := range []string{"Infof", "Printf", "Donef", "Warnf", "Errorf"} {
+		logger.On(method, mock.Anything).Return()
+		logger.On(method, mock.Anything, mock.Anything).Return()
+	}
+}
@@ -0,0 +1,44 @@
+package step
+
+const (
+	sauceLabsAppPathEnvKey     = "BITRISE_SAUCE_LABS_APP_PATH"
+	sauceLabsTestAppPathEnvKey = "BITRISE_SAUCE_LABS_TEST_APP_PATH"
+)
+
+// sauceLabsPackager creates the app under test and the UI test runner app (*-Runner.app) as ipas.
+// Sauce Labs only runs XCUITests, unit test targets are skipped.
+type sauceLabsPackager struct {
+	builder XcodebuildBuilder
+}
+
+func (p sauceLabsPackager) Package(opts ExportOpts, outputDir string) (PackagedArtifacts, error) {
+	products, err := p.builder.collectTestProducts(opts.SYMRoot, opts.XctestrunPths)
+	if err != nil {
+		return nil, err
+	}
+
+	apps, testApps := artifactCache{}, artifactCache{}
+	for _, product := range products {
+		if !product.IsUITestBundle {
+			p.builder.logger.Warnf("Skipping %s: Sauce Labs only runs UI tests", product.TestTarget)
+			continue
+		}
+
+		if product.App != "" {
+			ipaPth := ipaPath(outputDir, product.App)
+			if err := apps.create(ipaPth, func() error { return p.builder.createIPA(product.App, ipaPth, opts) }); err != nil {
+				return nil, err
+			}
+		}
+
+		ipaPth := ipaPath(outputDir, product.Runner)
+		if err := testApps.create(ipaPth, func() error { return p.builder.createIPA(product.Runner, ipaPth, opts) }); err != nil {
+			return nil, err
+		}
+	}
+
+	return PackagedArtifacts{
+		sauceLabsAppPathEnvKey:     apps.sorted(),
+		sauceLabsTestAppPathEnvKey: testApps.sorted(),
+	}, nil
+}
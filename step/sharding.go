@@ -0,0 +1,403 @@
+package step
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
+)
+
+const (
+	xctestrunShardPathListEnvKey = "BITRISE_XCTESTRUN_SHARD_PATH_LIST"
+
+	// defaultTestDuration is the estimated duration of a test, if no historical duration is available for any of the tests.
+	defaultTestDuration = time.Second
+)
+
+// xctestrunShardPattern matches the shard xctestrun files, they are written next to the xctestrun files of the build
+// and are excluded from the xctestrun discovery (for example when the build root is restored from a cache).
+var xctestrunShardPattern = regexp.MustCompile(`_shard-\d+-of-\d+\` + xctestrunExt + `$`)
+
+// testShard is a group of tests (Target/Class/method identifiers) and whole test targets (Target identifiers) to run on a single machine.
+type testShard struct {
+	Tests    []string
+	Duration time.Duration
+}
+
+// shardXctestruns splits the tests of every xctestrun file of the test bundle into shardCount balanced shards.
+func (b XcodebuildBuilder) shardXctestruns(bundle testBundle, shardCount int, junitPth string) ([]string, error) {
+	b.logger.Println()
+	b.logger.Infof("Sharding tests")
+
+	var historicalDurations map[string]time.Duration
+	if junitPth != "" {
+		data, err := os.ReadFile(junitPth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JUnit report: %w", err)
+		}
+		if historicalDurations, err = parseJUnitDurations(data); err != nil {
+			return nil, fmt.Errorf("failed to parse JUnit report (%s): %w", junitPth, err)
+		}
+	}
+
+	var shardPths []string
+	for _, xctestrunPth := range bundle.XctestrunPths {
+		pths, err := b.shardXctestrun(bundle.SYMRoot, xctestrunPth, shardCount, historicalDurations)
+		if err != nil {
+			return nil, err
+		}
+		shardPths = append(shardPths, pths...)
+	}
+
+	return shardPths, nil
+}
+
+// shardXctestrun splits the tests of the xctestrun file into shardCount balanced shards,
+// and writes an xctestrun file for every shard next to the xctestrun file, with the shard's tests set as OnlyTestIdentifiers.
+// Test targets without discovered tests can't be split, they are balanced as a whole, with an estimated duration.
+func (b XcodebuildBuilder) shardXctestrun(symRoot, xctestrunPth string, shardCount int, historicalDurations map[string]time.Duration) ([]string, error) {
+	testRun, err := b.readXctestrun(xctestrunPth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", xctestrunPth, err)
+	}
+
+	inventory, err := b.createTestInventory(symRoot, []string{xctestrunPth})
+	if err != nil {
+		return nil, err
+	}
+	testsByTarget := map[string][]string{}
+	for _, target := range inventory.TestTargets {
+		testsByTarget[target.Name] = target.Tests
+	}
+
+	var tests, unshardedTargets []string
+	selected := map[string]bool{}
+	for _, target := range testRun.TestTargets() {
+		targetTests := testsByTarget[target.BlueprintName]
+		if len(targetTests) == 0 {
+			if !selected[target.BlueprintName] {
+				b.logger.Warnf("No test discovered in %s, the whole test target is run by a single shard", target.BlueprintName)
+				selected[target.BlueprintName] = true
+				unshardedTargets = append(unshardedTargets, target.BlueprintName)
+			}
+			continue
+		}
+
+		for _, test := range targetTests {
+			if !selected[test] && isSelectedTest(target, test) {
+				selected[test] = true
+				tests = append(tests, test)
+			}
+		}
+	}
+	if len(tests) == 0 && len(unshardedTargets) == 0 {
+		return nil, fmt.Errorf("no test to shard in %s", filepath.Base(xctestrunPth))
+	}
+
+	durations := estimateTestDurations(tests, historicalDurations)
+	for target, duration := range estimateTargetDurations(unshardedTargets, durations, historicalDurations) {
+		durations[target] = duration
+	}
+
+	// there can't be more (non-empty) shards than tests, the shard files are named with the actual shard count
+	var shards []testShard
+	for _, shard := range balanceShards(append(tests, unshardedTargets...), durations, shardCount) {
+		if len(shard.Tests) > 0 {
+			shards = append(shards, shard)
+		}
+	}
+	if len(shards) < shardCount {
+		b.logger.Warnf("The tests of %s can't be split into %d shards, creating %d shard(s)", filepath.Base(xctestrunPth), shardCount, len(shards))
+	}
+
+	// shards of an earlier run (for example with an other shard count) are removed
+	if err := removeXctestrunShards(xctestrunPth); err != nil {
+		return nil, err
+	}
+
+	var shardPths []string
+	for i, shard := range shards {
+		shardPth := xctestrunShardPath(xctestrunPth, i+1, len(shards))
+		if err := b.writeXctestrunShard(xctestrunPth, shardPth, shard); err != nil {
+			return nil, err
+		}
+		b.logger.Printf("%s: %d test(s), estimated duration: %s", filepath.Base(shardPth), len(shard.Tests), shard.Duration)
+		shardPths = append(shardPths, shardPth)
+	}
+
+	return shardPths, nil
+}
+
+// writeXctestrunShard writes a copy of the xctestrun file which runs the tests of the shard only.
+// Test targets without tests in the shard are removed, as an empty OnlyTestIdentifiers list would run every test of the target.
+// Test targets assigned to the shard as a whole are kept unchanged.
+func (b XcodebuildBuilder) writeXctestrunShard(xctestrunPth, shardPth string, shard testShard) error {
+	testRun, err := b.readXctestrun(xctestrunPth)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", xctestrunPth, err)
+	}
+
+	wholeTargets := map[string]bool{}
+	testsByTarget := map[string][]string{}
+	for _, test := range shard.Tests {
+		target, classAndMethod, found := strings.Cut(test, "/")
+		if !found {
+			wholeTargets[target] = true
+			continue
+		}
+		testsByTarget[target] = append(testsByTarget[target], classAndMethod)
+	}
+
+	var configurations []*xctestrun.TestConfiguration
+	for _, configuration := range testRun.TestConfigurations {
+		var targets []*xctestrun.TestTarget
+		for _, target := range configuration.TestTargets {
+			if wholeTargets[target.BlueprintName] {
+				targets = append(targets, target)
+				continue
+			}
+
+			if tests := testsByTarget[target.BlueprintName]; len(tests) > 0 {
+				target.OnlyTestIdentifiers = tests
+				targets = append(targets, target)
+			}
+		}
+
+		if len(targets) > 0 {
+			configuration.TestTargets = targets
+			configurations = append(configurations, configuration)
+		}
+	}
+	testRun.TestConfigurations = configurations
+
+	return b.writeXctestrun(shardPth, testRun)
+}
+
+// isSelectedTest reports whether the test (Target/Class/method) is selected by the test target's
+// OnlyTestIdentifiers and SkipTestIdentifiers (Class or Class/method identifiers).
+func isSelectedTest(target *xctestrun.TestTarget, test string) bool {
+	_, classAndMethod, _ := strings.Cut(test, "/")
+	matches := func(identifiers []string) bool {
+		for _, identifier := range identifiers {
+			if classAndMethod == identifier || strings.HasPrefix(classAndMethod, identifier+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(target.OnlyTestIdentifiers) > 0 && !matches(target.OnlyTestIdentifiers) {
+		return false
+	}
+	return !matches(target.SkipTestIdentifiers)
+}
+
+// estimateTestDurations returns the historical duration of every test,
+// tests without history are estimated with the average historical duration.
+// Historical durations without a test target (Class/method) are used for the tests without a Target/Class/method duration.
+func estimateTestDurations(tests []string, historicalDurations map[string]time.Duration) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	var known time.Duration
+	for _, test := range tests {
+		_, classAndMethod, _ := strings.Cut(test, "/")
+		duration, ok := historicalDurations[test]
+		if !ok {
+			duration, ok = historicalDurations[classAndMethod]
+		}
+		if ok {
+			durations[test] = duration
+			known += duration
+		}
+	}
+
+	estimate := defaultTestDuration
+	if len(durations) > 0 {
+		estimate = known / time.Duration(len(durations))
+	}
+	for _, test := range tests {
+		if _, ok := durations[test]; !ok {
+			durations[test] = estimate
+		}
+	}
+
+	return durations
+}
+
+// estimateTargetDurations returns the duration of the test targets without discovered tests, which are run as a whole.
+// A test target's duration is the sum of its historical test durations (Target/Class/method),
+// test targets without history are estimated with the average duration of the test targets with discovered tests.
+func estimateTargetDurations(targets []string, testDurations, historicalDurations map[string]time.Duration) map[string]time.Duration {
+	discoveredTargets := map[string]bool{}
+	var discovered time.Duration
+	for test, duration := range testDurations {
+		target, _, _ := strings.Cut(test, "/")
+		discoveredTargets[target] = true
+		discovered += duration
+	}
+
+	estimate := defaultTestDuration
+	if len(discoveredTargets) > 0 {
+		estimate = discovered / time.Duration(len(discoveredTargets))
+	}
+
+	durations := map[string]time.Duration{}
+	for _, target := range targets {
+		var known time.Duration
+		for test, duration := range historicalDurations {
+			if strings.HasPrefix(test, target+"/") && strings.Count(test, "/") == 2 {
+				known += duration
+			}
+		}
+
+		if known > 0 {
+			durations[target] = known
+		} else {
+			durations[target] = estimate
+		}
+	}
+
+	return durations
+}
+
+// balanceShards distributes the tests (and whole test targets) into shardCount shards using the longest processing time first rule:
+// the tests are assigned in descending duration order, always to the shard with the shortest total duration
+// (or with fewer tests, so that zero duration tests are distributed too).
+func balanceShards(tests []string, durations map[string]time.Duration, shardCount int) []testShard {
+	sorted := append([]string{}, tests...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if durations[sorted[i]] != durations[sorted[j]] {
+			return durations[sorted[i]] > durations[sorted[j]]
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	shards := make([]testShard, shardCount)
+	for _, test := range sorted {
+		shortest := 0
+		for i := range shards {
+			if shards[i].Duration < shards[shortest].Duration ||
+				(shards[i].Duration == shards[shortest].Duration && len(shards[i].Tests) < len(shards[shortest].Tests)) {
+				shortest = i
+			}
+		}
+		shards[shortest].Tests = append(shards[shortest].Tests, test)
+		shards[shortest].Duration += durations[test]
+	}
+
+	for _, shard := range shards {
+		sort.Strings(shard.Tests)
+	}
+	return shards
+}
+
+type junitNode struct {
+	TestSuites []junitNode     `xml:"testsuite"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string `xml:"classname,attr"`
+	Name      string `xml:"name,attr"`
+	Time      string `xml:"time,attr"`
+}
+
+// parseJUnitDurations returns the test durations of a JUnit report by Target/Class/method, matching the discovered test identifiers.
+// The test target is the module prefix of the class name (BullsEyeTests.ScoreTests), class names without a module prefix
+// are keyed by Class/method. The parentheses of the test names (testPerfectHit()) are dropped.
+func parseJUnitDurations(data []byte) (map[string]time.Duration, error) {
+	var root junitNode
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	durations := map[string]time.Duration{}
+	var collect func(node junitNode) error
+	collect = func(node junitNode) error {
+		for _, testCase := range node.TestCases {
+			if testCase.Time == "" {
+				continue
+			}
+			seconds, err := strconv.ParseFloat(testCase.Time, 64)
+			if err != nil {
+				return fmt.Errorf("invalid time of %s.%s: %s", testCase.ClassName, testCase.Name, testCase.Time)
+			}
+
+			className := strings.Replace(testCase.ClassName, ".", "/", 1)
+			name := strings.TrimSuffix(testCase.Name, "()")
+			durations[className+"/"+name] = time.Duration(seconds * float64(time.Second))
+		}
+		for _, suite := range node.TestSuites {
+			if err := collect(suite); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := collect(root); err != nil {
+		return nil, err
+	}
+
+	return durations, nil
+}
+
+// xctestrunShardPath returns the path of a shard's xctestrun file, for example:
+// BullsEye_FullTests_iphonesimulator15.5-arm64_shard-1-of-4.xctestrun
+func xctestrunShardPath(xctestrunPth string, shard, shardCount int) string {
+	name := fmt.Sprintf("%s_shard-%d-of-%d%s", strings.TrimSuffix(filepath.Base(xctestrunPth), xctestrunExt), shard, shardCount, xctestrunExt)
+	return filepath.Join(filepath.Dir(xctestrunPth), name)
+}
+
+// xctestrunShards returns the shard xctestrun files written for the xctestrun file.
+func xctestrunShards(xctestrunPth string, shardPths []string) []string {
+	prefix := strings.TrimSuffix(xctestrunPth, xctestrunExt)
+	var shards []string
+	for _, shardPth := range shardPths {
+		if loc := xctestrunShardPattern.FindStringIndex(shardPth); loc != nil && shardPth[:loc[0]] == prefix {
+			shards = append(shards, shardPth)
+		}
+	}
+	return shards
+}
+
+// removeXctestrunShards removes the shard xctestrun files of the xctestrun file.
+func removeXctestrunShards(xctestrunPth string) error {
+	shardPths, err := filepath.Glob(strings.TrimSuffix(xctestrunPth, xctestrunExt) + "_shard-*" + xctestrunExt)
+	if err != nil {
+		return err
+	}
+	for _, shardPth := range shardPths {
+		if !xctestrunShardPattern.MatchString(shardPth) {
+			continue
+		}
+		if err := os.Remove(shardPth); err != nil {
+			return fmt.Errorf("failed to remove previous xctestrun shard: %w", err)
+		}
+	}
+	return nil
+}
+
+// xctestrunShardEntries returns the archive entries (relative to the build root) of the shard xctestrun files.
+func xctestrunShardEntries(shardPths []string) []string {
+	var entries []string
+	for _, shardPth := range shardPths {
+		entries = append(entries, filepath.Base(shardPth))
+	}
+	return entries
+}
+
+func (b XcodebuildBuilder) exportXctestrunShards(shardPths []string) error {
+	shardPthList := strings.Join(shardPths, "|")
+	if err := tools.ExportEnvironmentWithEnvman(xctestrunShardPathListEnvKey, shardPthList); err != nil {
+		return fmt.Errorf("failed to export %s: %w", xctestrunShardPathListEnvKey, err)
+	}
+	b.logger.Donef("The xctestrun files of the test shards are available in %s env: %s", xctestrunShardPathListEnvKey, shardPthList)
+	return nil
+}
@@ -0,0 +1,342 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_balanceShards(t *testing.T) {
+	tests := []struct {
+		name       string
+		tests      []string
+		durations  map[string]time.Duration
+		shardCount int
+		want       []testShard
+	}{
+		{
+			name:       "equal durations are distributed evenly",
+			tests:      []string{"T/A/test1", "T/A/test2", "T/A/test3", "T/B/test1"},
+			durations:  map[string]time.Duration{"T/A/test1": time.Second, "T/A/test2": time.Second, "T/A/test3": time.Second, "T/B/test1": time.Second},
+			shardCount: 2,
+			want: []testShard{
+				{Tests: []string{"T/A/test1", "T/A/test3"}, Duration: 2 * time.Second},
+				{Tests: []string{"T/A/test2", "T/B/test1"}, Duration: 2 * time.Second},
+			},
+		},
+		{
+			name:       "longest tests are assigned first",
+			tests:      []string{"T/A/slow", "T/A/fast1", "T/A/fast2", "T/A/medium"},
+			durations:  map[string]time.Duration{"T/A/slow": 6 * time.Second, "T/A/fast1": time.Second, "T/A/fast2": time.Second, "T/A/medium": 4 * time.Second},
+			shardCount: 2,
+			want: []testShard{
+				{Tests: []string{"T/A/slow"}, Duration: 6 * time.Second},
+				{Tests: []string{"T/A/fast1", "T/A/fast2", "T/A/medium"}, Duration: 6 * time.Second},
+			},
+		},
+		{
+			name:       "zero durations are distributed evenly",
+			tests:      []string{"T/A/test1", "T/A/test2"},
+			durations:  map[string]time.Duration{"T/A/test1": 0, "T/A/test2": 0},
+			shardCount: 2,
+			want: []testShard{
+				{Tests: []string{"T/A/test1"}},
+				{Tests: []string{"T/A/test2"}},
+			},
+		},
+		{
+			name:       "whole test targets are balanced with the tests",
+			tests:      []string{"T/A/test1", "T/A/test2", "U", "V"},
+			durations:  map[string]time.Duration{"T/A/test1": time.Second, "T/A/test2": time.Second, "U": 5 * time.Second, "V": 2 * time.Second},
+			shardCount: 2,
+			want: []testShard{
+				{Tests: []string{"U"}, Duration: 5 * time.Second},
+				{Tests: []string{"T/A/test1", "T/A/test2", "V"}, Duration: 4 * time.Second},
+			},
+		},
+		{
+			name:       "more shards than tests",
+			tests:      []string{"T/A/test1"},
+			durations:  map[string]time.Duration{"T/A/test1": time.Second},
+			shardCount: 2,
+			want: []testShard{
+				{Tests: []string{"T/A/test1"}, Duration: time.Second},
+				{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, balanceShards(tt.tests, tt.durations, tt.shardCount))
+		})
+	}
+}
+
+func Test_estimateTestDurations(t *testing.T) {
+	tests := []struct {
+		name                string
+		historicalDurations map[string]time.Duration
+		want                map[string]time.Duration
+	}{
+		{
+			name: "no history",
+			want: map[string]time.Duration{"T/A/test1": defaultTestDuration, "T/A/test2": defaultTestDuration, "T/A/test3": defaultTestDuration},
+		},
+		{
+			name:                "tests without history get the average duration",
+			historicalDurations: map[string]time.Duration{"T/A/test1": time.Second, "T/A/test2": 3 * time.Second},
+			want:                map[string]time.Duration{"T/A/test1": time.Second, "T/A/test2": 3 * time.Second, "T/A/test3": 2 * time.Second},
+		},
+		{
+			name:                "durations of an other target are not used",
+			historicalDurations: map[string]time.Duration{"T/A/test1": time.Second, "U/A/test2": 3 * time.Second},
+			want:                map[string]time.Duration{"T/A/test1": time.Second, "T/A/test2": time.Second, "T/A/test3": time.Second},
+		},
+		{
+			name:                "durations without target",
+			historicalDurations: map[string]time.Duration{"A/test1": time.Second, "A/test2": 3 * time.Second},
+			want:                map[string]time.Duration{"T/A/test1": time.Second, "T/A/test2": 3 * time.Second, "T/A/test3": 2 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, estimateTestDurations([]string{"T/A/test1", "T/A/test2", "T/A/test3"}, tt.historicalDurations))
+		})
+	}
+}
+
+func Test_estimateTargetDurations(t *testing.T) {
+	testDurations := map[string]time.Duration{"T/A/test1": time.Second, "T/A/test2": 3 * time.Second, "U/A/test1": 2 * time.Second}
+
+	tests := []struct {
+		name                string
+		testDurations       map[string]time.Duration
+		historicalDurations map[string]time.Duration
+		want                map[string]time.Duration
+	}{
+		{
+			name: "no discovered tests and no history",
+			want: map[string]time.Duration{"V": defaultTestDuration, "W": defaultTestDuration},
+		},
+		{
+			name:          "targets without history get the average duration of the discovered targets",
+			testDurations: testDurations,
+			want:          map[string]time.Duration{"V": 3 * time.Second, "W": 3 * time.Second},
+		},
+		{
+			name:                "targets with history get the sum of their test durations",
+			testDurations:       testDurations,
+			historicalDurations: map[string]time.Duration{"V/A/test1": 10 * time.Second, "V/B/test1": 5 * time.Second, "V/test1": time.Second},
+			want:                map[string]time.Duration{"V": 15 * time.Second, "W": 3 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, estimateTargetDurations([]string{"V", "W"}, tt.testDurations, tt.historicalDurations))
+		})
+	}
+}
+
+func Test_xctestrunShards(t *testing.T) {
+	shardPths := []string{
+		"/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun",
+		"/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-2-of-2.xctestrun",
+		"/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64-x_shard-1-of-1.xctestrun",
+		"/symroot/BullsEye_UITests_iphonesimulator15.5-arm64_shard-1-of-1.xctestrun",
+	}
+
+	require.Equal(t, []string{
+		"/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun",
+		"/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-2-of-2.xctestrun",
+	}, xctestrunShards("/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun", shardPths))
+}
+
+func Test_parseJUnitDurations(t *testing.T) {
+	junit := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="BullsEye">
+	<testsuite name="BullsEyeTests.ScoreTests" tests="2">
+		<testcase classname="BullsEyeTests.ScoreTests" name="testPerfectHit()" time="1.5"/>
+		<testcase classname="BullsEyeTests.ScoreTests" name="testMiss" time="0.25"/>
+	</testsuite>
+	<testsuite name="BullsEyeUITests.ScoreTests" tests="1">
+		<testcase classname="BullsEyeUITests.ScoreTests" name="testPerfectHit()" time="3"/>
+	</testsuite>
+	<testsuite name="BullsEyeObjCTests">
+		<testcase classname="BullsEyeObjCTests" name="testLegacyScoring" time="2"/>
+		<testcase classname="BullsEyeObjCTests" name="testNoTime"/>
+	</testsuite>
+</testsuites>`
+
+	durations, err := parseJUnitDurations([]byte(junit))
+
+	require.NoError(t, err)
+	require.Equal(t, map[string]time.Duration{
+		"BullsEyeTests/ScoreTests/testPerfectHit":   1500 * time.Millisecond,
+		"BullsEyeTests/ScoreTests/testMiss":         250 * time.Millisecond,
+		"BullsEyeUITests/ScoreTests/testPerfectHit": 3 * time.Second,
+		"BullsEyeObjCTests/testLegacyScoring":       2 * time.Second,
+	}, durations)
+}
+
+func Test_isSelectedTest(t *testing.T) {
+	tests := []struct {
+		name   string
+		target xctestrun.TestTarget
+		want   bool
+	}{
+		{name: "no selection", want: true},
+		{name: "only testing the class", target: xctestrun.TestTarget{OnlyTestIdentifiers: []string{"ScoreTests"}}, want: true},
+		{name: "only testing an other method", target: xctestrun.TestTarget{OnlyTestIdentifiers: []string{"ScoreTests/testMiss"}}, want: false},
+		{name: "skip testing the method", target: xctestrun.TestTarget{SkipTestIdentifiers: []string{"ScoreTests/testPerfectHit"}}, want: false},
+		{name: "skip testing a class with the same prefix", target: xctestrun.TestTarget{SkipTestIdentifiers: []string{"Score"}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isSelectedTest(&tt.target, "BullsEyeTests/ScoreTests/testPerfectHit"))
+		})
+	}
+}
+
+func Test_GivenDiscoveredTests_WhenShardXctestrun_ThenWritesXctestrunPerShard(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything).Return()
+
+	symRoot := t.TempDir()
+	testBundlePth := filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.app", "PlugIns", "BullsEyeTests.xctest")
+	require.NoError(t, os.MkdirAll(testBundlePth, 0755))
+	for _, name := range []string{"Info.plist", "BullsEyeTests"} {
+		content, err := os.ReadFile(filepath.Join("..", "testdiscovery", "testdata", "BullsEyeTests.xctest", name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(testBundlePth, name), content, 0755))
+	}
+
+	xctestrunPth := filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun")
+	earlierShardPth := filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-3-of-3.xctestrun")
+	require.NoError(t, os.WriteFile(earlierShardPth, xctestrunContent("BullsEyeTests"), 0644))
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(xctestrunContent("BullsEyeTests"), nil)
+
+	written := map[string][]byte{}
+	stepMocks.fileManager.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written[args.String(0)] = args.Get(1).([]byte)
+	})
+
+	junitPth := filepath.Join(t.TempDir(), "report.xml")
+	require.NoError(t, os.WriteFile(junitPth, []byte(`<testsuites><testsuite>
+<testcase classname="BullsEyeTests.BullsEyeTests" name="testAsyncHighScores()" time="5"/>
+<testcase classname="BullsEyeTests.BullsEyeTests" name="testScoring()" time="1"/>
+</testsuite></testsuites>`), 0644))
+
+	// When
+	shardPths, err := step.shardXctestruns(testBundle{SYMRoot: symRoot, XctestrunPths: []string{xctestrunPth}, DefaultXctestrunPth: xctestrunPth}, 2, junitPth)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun"),
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-2-of-2.xctestrun"),
+	}, shardPths)
+	require.NoFileExists(t, earlierShardPth)
+
+	wantOnlyTestIdentifiers := [][]string{
+		{"BullsEyeTests/testAsyncHighScores", "BullsEyeTests/testScoring"},
+		{"BullsEyeObjCTests/testLegacyScoring", "ScoreTests/testPerfectHit"},
+	}
+	for i, shardPth := range shardPths {
+		testRun, err := xctestrun.Parse(written[shardPth])
+		require.NoError(t, err)
+
+		targets := testRun.TestTargets()
+		require.Len(t, targets, 1)
+		require.Equal(t, wantOnlyTestIdentifiers[i], targets[0].OnlyTestIdentifiers)
+		require.Equal(t, "__TESTROOT__/Debug-iphonesimulator/BullsEye.app", targets[0].TestHostPath)
+	}
+}
+
+func Test_GivenMoreShardsThanTests_WhenShardXctestrun_ThenShardFilesAreNamedWithTheActualShardCount(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+
+	symRoot := t.TempDir()
+	testBundlePth := filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.app", "PlugIns", "BullsEyeTests.xctest")
+	require.NoError(t, os.MkdirAll(testBundlePth, 0755))
+	for _, name := range []string{"Info.plist", "BullsEyeTests"} {
+		content, err := os.ReadFile(filepath.Join("..", "testdiscovery", "testdata", "BullsEyeTests.xctest", name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(testBundlePth, name), content, 0755))
+	}
+
+	xctestrunPth := filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun")
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(xctestrunContent("BullsEyeTests"), nil)
+	stepMocks.fileManager.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	// When
+	shardPths, err := step.shardXctestruns(testBundle{SYMRoot: symRoot, XctestrunPths: []string{xctestrunPth}, DefaultXctestrunPth: xctestrunPth}, 10, "")
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-1-of-4.xctestrun"),
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-2-of-4.xctestrun"),
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-3-of-4.xctestrun"),
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-4-of-4.xctestrun"),
+	}, shardPths)
+}
+
+func Test_GivenMultipleXctestruns_WhenShardXctestruns_ThenShardsEveryXctestrun(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+
+	symRoot := t.TempDir()
+	testBundlePth := filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.app", "PlugIns", "BullsEyeTests.xctest")
+	require.NoError(t, os.MkdirAll(testBundlePth, 0755))
+	for _, name := range []string{"Info.plist", "BullsEyeTests"} {
+		content, err := os.ReadFile(filepath.Join("..", "testdiscovery", "testdata", "BullsEyeTests.xctest", name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(testBundlePth, name), content, 0755))
+	}
+
+	unitTestsPth := filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun")
+	uiTestsPth := filepath.Join(symRoot, "BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun")
+	stepMocks.fileManager.On("ReadFile", unitTestsPth).Return(xctestrunContent("BullsEyeTests"), nil)
+	stepMocks.fileManager.On("ReadFile", uiTestsPth).Return(xctestrunContent("BullsEyeUITests"), nil)
+
+	written := map[string][]byte{}
+	stepMocks.fileManager.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written[args.String(0)] = args.Get(1).([]byte)
+	})
+
+	// When
+	shardPths, err := step.shardXctestruns(testBundle{SYMRoot: symRoot, XctestrunPths: []string{unitTestsPth, uiTestsPth}, DefaultXctestrunPth: unitTestsPth}, 2, "")
+
+	// Then
+	require.NoError(t, err)
+	uiTestsShardPth := filepath.Join(symRoot, "BullsEye_UITests_iphonesimulator15.5-arm64_shard-1-of-1.xctestrun")
+	require.Equal(t, []string{
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun"),
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64_shard-2-of-2.xctestrun"),
+		uiTestsShardPth,
+	}, shardPths)
+
+	testRun, err := xctestrun.Parse(written[uiTestsShardPth])
+	require.NoError(t, err)
+	targets := testRun.TestTargets()
+	require.Len(t, targets, 1)
+	require.Equal(t, "BullsEyeUITests", targets[0].BlueprintName)
+	require.Empty(t, targets[0].OnlyTestIdentifiers)
+}
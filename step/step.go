@@ -2,12 +2,11 @@ package step
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/bitrise-io/go-steputils/output"
 	"github.com/bitrise-io/go-steputils/tools"
@@ -28,7 +27,9 @@ import (
 	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
 	"github.com/bitrise-io/go-xcode/xcodebuild"
 	cache "github.com/bitrise-io/go-xcode/xcodecache"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
 	"github.com/bitrise-steplib/steps-xcode-build-for-test/xcodeproject"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
 	"github.com/kballard/go-shellquote"
 )
 
@@ -38,6 +39,15 @@ const (
 	xctestrunPathEnvKey     = "BITRISE_XCTESTRUN_FILE_PATH"
 	xcodebuildLogPathEnvKey = "BITRISE_XCODE_RAW_RESULT_TEXT_PATH"
 	xcodebuildLogBaseName   = "raw-xcodebuild-output.log"
+
+	testBundleZipPathListEnvKey = "BITRISE_TEST_BUNDLE_ZIP_PATH_LIST"
+	xctestrunPathListEnvKey     = "BITRISE_XCTESTRUN_FILE_PATH_LIST"
+	xctestrunManifestPathEnvKey = "BITRISE_XCTESTRUN_MANIFEST_PATH"
+	xctestrunManifestBaseName   = "xctestrun-manifest.json"
+	testBundleHashEnvKey        = "BITRISE_TEST_BUNDLE_HASH"
+
+	testBundleArchivePathEnvKey     = "BITRISE_TEST_BUNDLE_ARCHIVE_PATH"
+	testBundleArchivePathListEnvKey = "BITRISE_TEST_BUNDLE_ARCHIVE_PATH_LIST"
 )
 
 const xctestrunExt = ".xctestrun"
@@ -57,7 +67,7 @@ type Input struct {
 	ProjectPath   string `env:"project_path,required"`
 	Scheme        string `env:"scheme,required"`
 	Configuration string `env:"configuration"`
-	Destination   string `env:"destination,required"`
+	Destinations  string `env:"destination,required"`
 	TestPlan      string `env:"test_plan"`
 	// xcodebuild configuration
 	XCConfigContent   string `env:"xcconfig_content"`
@@ -78,7 +88,41 @@ type Input struct {
 	BuildAPIToken                   stepconf.Secret `env:"BITRISE_BUILD_API_TOKEN"`
 	FallbackProvisioningProfileURLs string          `env:"fallback_provisioning_profile_url_list"`
 	// Step output configuration
-	OutputDir string `env:"output_dir,required"`
+	OutputDir      string `env:"output_dir,required"`
+	IntegrityCheck string `env:"test_bundle_integrity_check,opt[fail,warn]"`
+	Packaging      string `env:"test_bundle_packaging,opt[single,per_xctestrun,per_destination]"`
+	Reproducible   bool   `env:"reproducible_test_bundle,opt[yes,no]"`
+	ArchiveFormat  string `env:"archive_format,opt[zip,tar.gz,tar.zst,none]"`
+	Prune          bool   `env:"prune_test_bundle,opt[yes,no]"`
+	PruneAllowList string `env:"prune_allow_list"`
+	PruneDenyList  string `env:"prune_deny_list"`
+	ExportDSYMs    bool   `env:"export_dsyms,opt[yes,no]"`
+	ResultBundle   bool   `env:"export_result_bundle,opt[yes,no]"`
+	TestInventory  bool   `env:"export_test_inventory,opt[yes,no]"`
+	// Device farm packaging
+	PackagingProfile string `env:"packaging_profile,opt[none,firebase-test-lab,browserstack,sauce-labs,aws-device-farm]"`
+	IPAExport        string `env:"ipa_export,opt[none,unsigned,signed]"`
+	// Test sharding
+	ShardCount     int    `env:"shard_count,range[0..100]"`
+	ShardJUnitPath string `env:"shard_junit_path"`
+	// xctestrun customization
+	XctestrunEnvironmentVariables        string `env:"xctestrun_environment_variables"`
+	XctestrunTestingEnvironmentVariables string `env:"xctestrun_testing_environment_variables"`
+	XctestrunCommandLineArguments        string `env:"xctestrun_command_line_arguments"`
+	XctestrunCustomizedTestTargets       string `env:"xctestrun_customized_test_targets"`
+	// Test selection
+	OnlyTesting         string `env:"only_testing"`
+	OnlyTestingFilePath string `env:"only_testing_file_path"`
+	SkipTesting         string `env:"skip_testing"`
+	SkipTestingFilePath string `env:"skip_testing_file_path"`
+	// Swift package resolution
+	ResolvePackages            bool `env:"resolve_packages,opt[yes,no]"`
+	ResolvePackagesTimeout     int  `env:"resolve_packages_timeout,range[0..7200]"`
+	ResolvePackagesMaxAttempts int  `env:"resolve_packages_max_attempts,range[1..10]"`
+	// Build retry
+	RetryMaxAttempts int    `env:"retry_max_attempts,range[1..10]"`
+	RetryBackoff     int    `env:"retry_backoff,range[0..600]"`
+	RetrySignatures  string `env:"retry_signatures"`
 	// Caching
 	CacheLevel string `env:"cache_level,opt[none,swift_packages]"`
 	// App Store Connect connection override
@@ -95,14 +139,34 @@ type Config struct {
 	ProjectPath            string
 	Scheme                 string
 	Configuration          string
-	Destination            string
-	TestPlan               string
+	Destinations           []string
+	TestPlans              []string
 	XCConfig               string
 	XcodebuildOptions      []string
 	LogFormatter           string
 	CodesignManager        *codesign.Manager
 	OutputDir              string
+	IntegrityCheck         string
+	Packaging              string
+	Reproducible           bool
+	ArchiveFormat          string
+	Prune                  bool
+	PruneAllowList         []string
+	PruneDenyList          []string
+	ExportDSYMs            bool
+	ResultBundle           bool
+	TestInventory          bool
+	PackagingProfile       string
+	IPAExport              string
+	ShardCount             int
+	ShardJUnitPath         string
+	XctestrunCustomization xctestrunCustomization
+	TestSelection          testSelection
+	RetryPolicy            retryPolicy
+	ResolvePackages        bool
+	PackageResolution      packageResolution
 	CompressionLevel       int
+	XcodeVersion           string
 	XcodebuildMajorVersion int
 	CacheLevel             string
 	SwiftPackagesPath      string
@@ -119,6 +183,10 @@ type XcodebuildBuilder struct {
 	fileManager        FileManager
 	logger             v2log.Logger
 	cmdFactory         command.Factory
+	archiver           archiver.Archiver
+	sleeper            Sleeper
+	// packageResolutionRunner runs the Swift package resolution, its command factory applies the resolution timeout.
+	packageResolutionRunner xcodecommand.Runner
 }
 
 func NewXcodebuildBuilder(
@@ -132,18 +200,24 @@ func NewXcodebuildBuilder(
 	fileManager FileManager,
 	logger v2log.Logger,
 	cmdFactory command.Factory,
+	archiver archiver.Archiver,
+	sleeper Sleeper,
+	packageResolutionRunner xcodecommand.Runner,
 ) XcodebuildBuilder {
 	return XcodebuildBuilder{
-		xcodeCommandRunner: xcodeCommandRunner,
-		xcodeproject:       xcodeproject,
-		logFormatter:       logFormatter,
-		xcodeVersionReader: xcodeVersionReader,
-		pathProvider:       pathProvider,
-		pathChecker:        pathChecker,
-		pathModifier:       pathModifier,
-		fileManager:        fileManager,
-		logger:             logger,
-		cmdFactory:         cmdFactory,
+		xcodeCommandRunner:      xcodeCommandRunner,
+		xcodeproject:            xcodeproject,
+		logFormatter:            logFormatter,
+		xcodeVersionReader:      xcodeVersionReader,
+		pathProvider:            pathProvider,
+		pathChecker:             pathChecker,
+		pathModifier:            pathModifier,
+		fileManager:             fileManager,
+		logger:                  logger,
+		cmdFactory:              cmdFactory,
+		archiver:                archiver,
+		sleeper:                 sleeper,
+		packageResolutionRunner: packageResolutionRunner,
 	}
 }
 
@@ -220,6 +294,68 @@ func (c ConfigParser) ProcessConfig() (Config, error) {
 		}
 	}
 
+	destinations := parseList(input.Destinations)
+	if len(destinations) == 0 {
+		return Config{}, fmt.Errorf("no destination specified")
+	}
+
+	if input.PackagingProfile != packagingProfileNone {
+		if err := validateDeviceDestination(input.PackagingProfile, destinations); err != nil {
+			return Config{}, err
+		}
+	}
+
+	pruneAllowList := parseList(input.PruneAllowList)
+	if err := archiver.ValidatePatterns(pruneAllowList); err != nil {
+		return Config{}, fmt.Errorf("invalid pattern in the pruning allow list: %w", err)
+	}
+	pruneDenyList := parseList(input.PruneDenyList)
+	if err := archiver.ValidatePatterns(pruneDenyList); err != nil {
+		return Config{}, fmt.Errorf("invalid pattern in the pruning deny list: %w", err)
+	}
+
+	environmentVariables, err := parseEnvironmentVariables(input.XctestrunEnvironmentVariables)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid xctestrun environment variables: %w", err)
+	}
+	testingEnvironmentVariables, err := parseEnvironmentVariables(input.XctestrunTestingEnvironmentVariables)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid xctestrun testing environment variables: %w", err)
+	}
+	customization := xctestrunCustomization{
+		EnvironmentVariables:        environmentVariables,
+		TestingEnvironmentVariables: testingEnvironmentVariables,
+		CommandLineArguments:        parseLines(input.XctestrunCommandLineArguments),
+		TestTargets:                 parseList(input.XctestrunCustomizedTestTargets),
+	}
+
+	onlyTesting, err := parseTestIdentifiers(input.OnlyTesting, input.OnlyTestingFilePath)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid only testing identifiers: %w", err)
+	}
+	skipTesting, err := parseTestIdentifiers(input.SkipTesting, input.SkipTestingFilePath)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid skip testing identifiers: %w", err)
+	}
+
+	retryRules, err := parseRetryRules(input.RetrySignatures)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid retry signatures: %w", err)
+	}
+	policy := retryPolicy{
+		MaxAttempts: input.RetryMaxAttempts,
+		Backoff:     time.Duration(input.RetryBackoff) * time.Second,
+		Rules:       append(append([]retryRule{}, defaultRetryRules...), retryRules...),
+	}
+	resolution := packageResolution{
+		Timeout: time.Duration(input.ResolvePackagesTimeout) * time.Second,
+		RetryPolicy: retryPolicy{
+			MaxAttempts: input.ResolvePackagesMaxAttempts,
+			Backoff:     policy.Backoff,
+			Rules:       policy.Rules,
+		},
+	}
+
 	var codesignManager *codesign.Manager
 	if input.CodeSigningAuthSource != codeSignSourceOff {
 		factory := v2command.NewFactory(env.NewRepository())
@@ -257,14 +393,34 @@ func (c ConfigParser) ProcessConfig() (Config, error) {
 		ProjectPath:            absProjectPath,
 		Scheme:                 input.Scheme,
 		Configuration:          input.Configuration,
-		Destination:            input.Destination,
-		TestPlan:               input.TestPlan,
+		Destinations:           destinations,
+		TestPlans:              parseList(input.TestPlan),
 		XCConfig:               input.XCConfigContent,
 		XcodebuildOptions:      customOptions,
 		LogFormatter:           input.LogFormatter,
 		CodesignManager:        codesignManager,
 		OutputDir:              absOutputDir,
+		IntegrityCheck:         input.IntegrityCheck,
+		Packaging:              input.Packaging,
+		Reproducible:           input.Reproducible,
+		ArchiveFormat:          input.ArchiveFormat,
+		Prune:                  input.Prune,
+		PruneAllowList:         pruneAllowList,
+		PruneDenyList:          pruneDenyList,
+		ExportDSYMs:            input.ExportDSYMs,
+		ResultBundle:           input.ResultBundle,
+		TestInventory:          input.TestInventory,
+		PackagingProfile:       input.PackagingProfile,
+		IPAExport:              input.IPAExport,
+		ShardCount:             input.ShardCount,
+		ShardJUnitPath:         input.ShardJUnitPath,
+		XctestrunCustomization: customization,
+		TestSelection:          testSelection{OnlyTesting: onlyTesting, SkipTesting: skipTesting},
+		RetryPolicy:            policy,
+		ResolvePackages:        input.ResolvePackages && xcodebuildVersion.MajorVersion >= 11,
+		PackageResolution:      resolution,
 		CompressionLevel:       input.CompressionLevel,
+		XcodeVersion:           fmt.Sprintf("%s (%s)", xcodebuildVersion.Version, xcodebuildVersion.BuildVersion),
 		XcodebuildMajorVersion: int(xcodebuildVersion.MajorVersion),
 		CacheLevel:             input.CacheLevel,
 		SwiftPackagesPath:      swiftPackagesPath,
@@ -289,13 +445,48 @@ func (b *XcodebuildBuilder) EnsureDependencies() {
 }
 
 type RunOut struct {
-	XcodebuildLog       string
-	XctestrunPths       []string
-	DefaultXctestrunPth string
-	SYMRoot             string
+	XcodebuildLog           string
+	XctestrunPths           []string
+	DefaultXctestrunPth     string
+	XctestrunPthsByTestPlan xctestrunsByTestPlan
+	SYMRoot                 string
+	TestBundleManifest      *testBundleManifest
+	TestTargets             *testTargetsReport
+	XctestrunShardPths      []string
+	XcresultPth             string
+	// BuildErrors and BuildFailure describe the errors of the xcodebuild log, only set if the build failed.
+	BuildErrors  []buildError
+	BuildFailure *buildFailure
 }
 
 func (b XcodebuildBuilder) Run(cfg Config) (RunOut, error) {
+	if len(cfg.TestPlans) > 0 {
+		// xcodebuild fails on an unknown test plan anyway, the validation only gives an early, more descriptive error
+		scheme, err := b.xcodeproject.Scheme(cfg.ProjectPath, cfg.Scheme)
+		if err != nil {
+			b.logger.Warnf("Failed to read %s scheme, the requested test plans are not validated: %s", cfg.Scheme, err)
+		} else if scheme.TestAction.TestPlans == nil || len(scheme.TestAction.TestPlans.TestPlanReferences) == 0 {
+			b.logger.Warnf("%s scheme does not list any test plan, the requested test plans are not validated", cfg.Scheme)
+		} else if err := validateTestPlans(scheme, cfg.TestPlans); err != nil {
+			return RunOut{}, err
+		}
+	}
+
+	// Resolve Swift packages
+	if cfg.ResolvePackages {
+		resolutionLog, err := b.resolvePackages(cfg)
+		if err != nil {
+			failure := classifyPackageResolutionFailure(resolutionLog)
+			b.printBuildFailure(failure)
+			result := RunOut{
+				XcodebuildLog: resolutionLog,
+				BuildErrors:   findBuildErrors(resolutionLog),
+				BuildFailure:  &failure,
+			}
+			return result, wrapBuildError(fmt.Errorf("failed to resolve Swift package dependencies: %w", err), result.BuildErrors)
+		}
+	}
+
 	// Automatic code signing
 	authOptions, err := b.automaticCodeSigning(cfg.CodesignManager)
 	if err != nil {
@@ -316,10 +507,22 @@ func (b XcodebuildBuilder) Run(cfg Config) (RunOut, error) {
 	xcodeBuildCmd := xcodebuild.NewCommandBuilder(cfg.ProjectPath, "build-for-testing")
 	xcodeBuildCmd.SetScheme(cfg.Scheme)
 	xcodeBuildCmd.SetConfiguration(cfg.Configuration)
-	xcodeBuildCmd.SetDestination(cfg.Destination)
-	xcodeBuildCmd.SetTestPlan(cfg.TestPlan)
+	xcodeBuildCmd.SetDestination(cfg.Destinations[0])
+	if len(cfg.TestPlans) == 1 {
+		xcodeBuildCmd.SetTestPlan(cfg.TestPlans[0])
+	} else if len(cfg.TestPlans) > 1 {
+		// xcodebuild builds a single or every test plan, the xctestrun files of the not requested test plans are dropped after the build
+		b.logger.Printf("Building every test plan, keeping the xctestrun files of: %s", strings.Join(cfg.TestPlans, ", "))
+	}
 
-	options := cfg.XcodebuildOptions
+	options := append([]string{}, cfg.XcodebuildOptions...)
+	if len(cfg.Destinations) > 1 {
+		// xcodebuild builds every destination in one invocation, sharing the DerivedData and the resolved packages
+		b.logger.Printf("Building for %d destinations: %s", len(cfg.Destinations), strings.Join(cfg.Destinations, ", "))
+		for _, destination := range cfg.Destinations[1:] {
+			options = append(options, "-destination", destination)
+		}
+	}
 	symRoot := findBuildSetting(options, "SYMROOT")
 	if symRoot == "" {
 		symRoot, err = b.pathModifier.AbsPath("./test_bundle")
@@ -346,8 +549,32 @@ func (b XcodebuildBuilder) Run(cfg Config) (RunOut, error) {
 		xcodeBuildCmd.SetAuthentication(*authOptions)
 	}
 
+	var resultBundlePth string
+	if cfg.ResultBundle {
+		// xcodebuild fails if the -resultBundlePath option is passed twice, the result bundle path of the xcodebuild options is exported instead
+		resultBundlePth = findOptionValue(options, "-resultBundlePath")
+		if resultBundlePth != "" {
+			b.logger.Printf("Using the result bundle path of the xcodebuild options: %s", resultBundlePth)
+		} else {
+			resultBundlePth = xcresultPath(cfg.OutputDir)
+			xcodeBuildCmd.SetResultBundlePath(resultBundlePth)
+		}
+
+		// xcodebuild fails if the result bundle already exists
+		if err := os.RemoveAll(resultBundlePth); err != nil {
+			return RunOut{}, fmt.Errorf("failed to remove previous result bundle: %w", err)
+		}
+	}
+
 	result := RunOut{}
-	rawXcodebuildOut, err := runCommandWithRetry(b.xcodeCommandRunner, b.logFormatter, xcodeBuildCmd, cfg.SwiftPackagesPath, b.logger)
+	cleanupPaths := retryPaths{
+		SwiftPackagesPath: cfg.SwiftPackagesPath,
+		ResultBundlePath:  resultBundlePth,
+	}
+	if cfg.SwiftPackagesPath != "" {
+		cleanupPaths.DerivedDataPath = filepath.Dir(cfg.SwiftPackagesPath)
+	}
+	rawXcodebuildOut, err := runCommandWithRetry(b.xcodeCommandRunner, b.logFormatter, xcodeBuildCmd, cfg.RetryPolicy, cleanupPaths, b.sleeper, b.logger)
 	// TODO: if output_tool == xcodebuild, the build log is printed to stdout + last couple of lines printed again
 	if err != nil || b.logFormatter == XcodebuildTool {
 		printLastLinesOfXcodebuildTestLog(rawXcodebuildOut, err == nil, b.logger)
@@ -355,8 +582,20 @@ func (b XcodebuildBuilder) Run(cfg Config) (RunOut, error) {
 
 	result.XcodebuildLog = rawXcodebuildOut
 
+	if resultBundlePth != "" {
+		if exists, existsErr := b.pathChecker.IsDirExists(resultBundlePth); existsErr == nil && exists {
+			result.XcresultPth = resultBundlePth
+		} else {
+			b.logger.Warnf("xcodebuild did not create the result bundle (%s)", resultBundlePth)
+		}
+	}
+
 	if err != nil {
-		return result, err
+		result.BuildErrors = findBuildErrors(rawXcodebuildOut)
+		failure := classifyBuildFailure(rawXcodebuildOut)
+		b.printBuildFailure(failure)
+		result.BuildFailure = &failure
+		return result, wrapBuildError(err, result.BuildErrors)
 	}
 
 	// Cache swift packages
@@ -373,15 +612,63 @@ func (b XcodebuildBuilder) Run(cfg Config) (RunOut, error) {
 		SYMRoot:     symRoot,
 		ProjectPath: cfg.ProjectPath,
 		Scheme:      cfg.Scheme,
+		TestPlans:   cfg.TestPlans,
 	})
 	if err != nil {
 		return result, err
 	}
 
+	if err := b.customizeXctestruns(testBundle.XctestrunPths, cfg.XctestrunCustomization); err != nil {
+		return result, fmt.Errorf("failed to customize xctestrun files: %w", err)
+	}
+
+	scheme, err := b.xcodeproject.Scheme(cfg.ProjectPath, cfg.Scheme)
+	if err != nil {
+		b.logger.Warnf("Failed to read %s scheme, test targets are listed without the scheme's flags and the test selection is validated against the xctestrun files: %s", cfg.Scheme, err)
+		scheme = nil
+	}
+
+	testBundle, err = b.applyTestSelection(testBundle, cfg.TestSelection, scheme)
+	if err != nil {
+		return result, fmt.Errorf("failed to apply test selection: %w", err)
+	}
+
+	integrityReports, err := b.checkTestBundleIntegrity(testBundle)
+	if err != nil {
+		return result, err
+	}
+	if err := b.reportTestBundleIntegrity(integrityReports, cfg.IntegrityCheck); err != nil {
+		return result, err
+	}
+
 	result.XctestrunPths = testBundle.XctestrunPths
 	result.DefaultXctestrunPth = testBundle.DefaultXctestrunPth
+	result.XctestrunPthsByTestPlan = testBundle.XctestrunPthsByTestPlan
 	result.SYMRoot = testBundle.SYMRoot
 
+	testTargets, err := b.createTestTargetsReport(testBundle.SYMRoot, testBundle.XctestrunPths, scheme)
+	if err != nil {
+		b.logger.Warnf("Failed to list test targets: %s", err)
+	} else {
+		b.printTestTargetsReport(testTargets)
+		result.TestTargets = &testTargets
+	}
+
+	if cfg.ShardCount > 1 {
+		shardPths, err := b.shardXctestruns(testBundle, cfg.ShardCount, cfg.ShardJUnitPath)
+		if err != nil {
+			return result, fmt.Errorf("failed to shard tests: %w", err)
+		}
+		result.XctestrunShardPths = shardPths
+	}
+
+	manifest, err := b.createTestBundleManifest(cfg, testBundle, result.XctestrunShardPths)
+	if err != nil {
+		b.logger.Warnf("Failed to create test bundle manifest: %s", err)
+	} else {
+		result.TestBundleManifest = &manifest
+	}
+
 	return result, nil
 }
 
@@ -389,6 +676,16 @@ type ExportOpts struct {
 	RunOut
 	OutputDir        string
 	CompressionLevel int
+	Packaging        string
+	Reproducible     bool
+	ArchiveFormat    string
+	Prune            bool
+	PruneAllowList   []string
+	PruneDenyList    []string
+	ExportDSYMs      bool
+	TestInventory    bool
+	PackagingProfile string
+	IPAExport        string
 }
 
 func (b XcodebuildBuilder) ExportOutputs(opts ExportOpts) error {
@@ -401,14 +698,88 @@ func (b XcodebuildBuilder) ExportOutputs(opts ExportOpts) error {
 		}
 	}
 
+	if opts.BuildErrors != nil {
+		if err := b.exportBuildErrors(opts.OutputDir, opts.BuildErrors, opts.BuildFailure); err != nil {
+			b.logger.Warnf("%s", err)
+		}
+	}
+
+	if opts.BuildFailure != nil {
+		if err := b.exportBuildFailureCategory(*opts.BuildFailure); err != nil {
+			b.logger.Warnf("%s", err)
+		}
+	}
+
+	if opts.XcresultPth != "" {
+		if err := b.exportXcresult(opts); err != nil {
+			b.logger.Warnf("%s", err)
+		}
+	}
+
 	if len(opts.XctestrunPths) == 0 {
 		return nil
 	}
 
-	if err := b.exportTestBundle(opts.OutputDir, opts.CompressionLevel, opts.SYMRoot, opts.XctestrunPths, opts.DefaultXctestrunPth); err != nil {
+	testBundleArchivePth, err := b.exportTestBundle(opts)
+	if err != nil {
+		b.logger.Warnf("%s", err)
+	}
+
+	var testBundleHash string
+	if testBundleArchivePth != "" {
+		testBundleHash, err = b.exportTestBundleHash(testBundleArchivePth)
+		if err != nil {
+			b.logger.Warnf("%s", err)
+		}
+	}
+
+	if opts.ExportDSYMs {
+		if err := b.exportTestBundleDSYMs(opts); err != nil {
+			b.logger.Warnf("Failed to export dSYMs: %s", err)
+		}
+	}
+
+	if err := b.exportXctestruns(opts.OutputDir, opts.XctestrunPths, opts.XctestrunPthsByTestPlan); err != nil {
 		b.logger.Warnf("%s", err)
 	}
 
+	if len(opts.XctestrunShardPths) > 0 {
+		if err := b.exportXctestrunShards(opts.XctestrunShardPths); err != nil {
+			b.logger.Warnf("%s", err)
+		}
+	}
+
+	if opts.TestBundleManifest != nil {
+		if err := b.exportTestBundleManifest(opts.OutputDir, *opts.TestBundleManifest, testBundleArchivePth, testBundleHash); err != nil {
+			b.logger.Warnf("%s", err)
+		}
+	}
+
+	if opts.TestTargets != nil {
+		if err := b.exportTestTargetsReport(opts.OutputDir, *opts.TestTargets); err != nil {
+			b.logger.Warnf("%s", err)
+		}
+	}
+
+	if opts.TestInventory {
+		if err := b.exportTestInventory(opts); err != nil {
+			b.logger.Warnf("Failed to export test inventory: %s", err)
+		}
+	}
+
+	if opts.IPAExport != "" && opts.IPAExport != ipaExportNone {
+		if err := b.exportIPAs(opts); err != nil {
+			b.logger.Warnf("Failed to export ipa files: %s", err)
+		}
+	}
+
+	if opts.PackagingProfile != "" && opts.PackagingProfile != packagingProfileNone {
+		// the selected device farm expects its packages, a failed packaging fails the Step
+		if err := b.exportPackagedTestBundle(opts); err != nil {
+			return fmt.Errorf("failed to package the test bundle for %s: %w", opts.PackagingProfile, err)
+		}
+	}
+
 	return nil
 }
 
@@ -447,12 +818,15 @@ type findTestBundleOpts struct {
 	SYMRoot     string
 	ProjectPath string
 	Scheme      string
+	// TestPlans are the requested test plans, the xctestrun files of other test plans are dropped.
+	TestPlans []string
 }
 
 type testBundle struct {
-	XctestrunPths       []string
-	DefaultXctestrunPth string
-	SYMRoot             string
+	XctestrunPths           []string
+	DefaultXctestrunPth     string
+	XctestrunPthsByTestPlan xctestrunsByTestPlan
+	SYMRoot                 string
 }
 
 // findTestBundle searches for the built target, associated tests and xctestrun file(s) in the build root (SYMROOT).
@@ -485,11 +859,18 @@ func (b XcodebuildBuilder) findTestBundle(opts findTestBundleOpts) (testBundle,
 	var xctestrunPths []string
 	for _, entry := range entries {
 		ext := filepath.Ext(entry.Name())
+		if ext == xctestrunExt && xctestrunShardPattern.MatchString(entry.Name()) {
+			b.logger.Debugf("Ignoring %s, it is an xctestrun shard", entry.Name())
+			continue
+		}
 		if ext == xctestrunExt {
 			absXctestrunPth := filepath.Join(opts.SYMRoot, entry.Name())
 			if err := b.fixTestRoot(absXctestrunPth); err != nil {
 				return testBundle{}, fmt.Errorf("failed to apply TESTROOT fix on %s: %s", absXctestrunPth, err)
 			}
+			if err := b.validateXctestrun(absXctestrunPth); err != nil {
+				b.logger.Warnf("%s: %s", filepath.Base(absXctestrunPth), err)
+			}
 			xctestrunPths = append(xctestrunPths, absXctestrunPth)
 		}
 	}
@@ -500,26 +881,45 @@ func (b XcodebuildBuilder) findTestBundle(opts findTestBundleOpts) (testBundle,
 
 	b.logger.Donef("xctestrun file(s) generated during the build:\n- %s", strings.Join(xctestrunPths, "\n- "))
 
+	xctestrunPthsByTestPlan := b.mapXctestrunsByTestPlan(xctestrunPths, opts.Scheme)
+
+	if len(opts.TestPlans) > 0 {
+		generatedXctestrunPths := xctestrunPths
+		xctestrunPths, xctestrunPthsByTestPlan = filterXctestrunsByTestPlan(xctestrunPths, xctestrunPthsByTestPlan, opts.TestPlans)
+		if len(xctestrunPths) == 0 {
+			return testBundle{}, fmt.Errorf("no xctestrun file generated for the %s test plan(s)", strings.Join(opts.TestPlans, ", "))
+		}
+		b.removeFilteredXctestruns(generatedXctestrunPths, xctestrunPths)
+		for _, testPlan := range opts.TestPlans {
+			if _, ok := xctestrunPthsByTestPlan[testPlan]; !ok {
+				b.logger.Warnf("No xctestrun file generated for the %s test plan", testPlan)
+			}
+		}
+	}
+
 	// find default xctestrun file
 	var defaultXctestrunPth string
 	if len(xctestrunPths) > 1 {
-		scheme, err := b.xcodeproject.Scheme(opts.ProjectPath, opts.Scheme)
-		if err != nil {
-			return testBundle{}, err
-		}
-
-		var defaultTestPlanName string
-		if testPlan := scheme.DefaultTestPlan(); testPlan != nil {
-			defaultTestPlanName = testPlan.Name()
+		var defaultTestPlanName, reason string
+		if len(opts.TestPlans) > 0 {
+			defaultTestPlanName = opts.TestPlans[0]
+			reason = "the first requested test plan"
+		} else {
+			scheme, err := b.xcodeproject.Scheme(opts.ProjectPath, opts.Scheme)
+			if err != nil {
+				return testBundle{}, err
+			}
+			if testPlan := scheme.DefaultTestPlan(); testPlan != nil {
+				defaultTestPlanName = testPlan.Name()
+			}
+			reason = fmt.Sprintf("%s scheme's default test plan", scheme.Name)
 		}
 
 		if defaultTestPlanName != "" {
-			for _, xctestrunPth := range xctestrunPths {
-				// xctestrun file name layout with Test Plans: <scheme>_<test_plan>_<destination>.xctestrun
-				if strings.Contains(filepath.Base(xctestrunPth), fmt.Sprintf("_%s_", defaultTestPlanName)) {
-					b.logger.Donef("default xctestrun based on %s scheme's default test plan (%s): %s", scheme.Name, defaultTestPlanName, xctestrunPth)
-					defaultXctestrunPth = xctestrunPth
-				}
+			// the test plan -> xctestrun mapping is based on the xctestrun content (or the exact file name layout), a test plan name being a substring of an other one doesn't matter
+			if xctestrunPth := testPlanXctestrun(xctestrunPths, xctestrunPthsByTestPlan, defaultTestPlanName); xctestrunPth != "" {
+				b.logger.Donef("default xctestrun based on %s (%s): %s", reason, defaultTestPlanName, xctestrunPth)
+				defaultXctestrunPth = xctestrunPth
 			}
 		}
 	}
@@ -528,9 +928,10 @@ func (b XcodebuildBuilder) findTestBundle(opts findTestBundleOpts) (testBundle,
 	}
 
 	return testBundle{
-		XctestrunPths:       xctestrunPths,
-		DefaultXctestrunPth: defaultXctestrunPth,
-		SYMRoot:             opts.SYMRoot,
+		XctestrunPths:           xctestrunPths,
+		DefaultXctestrunPth:     defaultXctestrunPth,
+		XctestrunPthsByTestPlan: xctestrunPthsByTestPlan,
+		SYMRoot:                 opts.SYMRoot,
 	}, nil
 }
 
@@ -549,15 +950,68 @@ func (b XcodebuildBuilder) findTestBundle(opts findTestBundleOpts) (testBundle,
 // - find all the existing xctestrun files in Xcode's DerivedData dir
 // - filter them for the build's timeframe (so that only the current step generated outputs are considered)
 // - find the built targets and tests dir based on the configuration and destination inputs
+//
+// The paths are rewritten in the parsed xctestrun, if the file can not be parsed, the replacement is done on its raw content.
+// The file is only written if a path changed.
 func (b XcodebuildBuilder) fixTestRoot(xctestrunPth string) error {
 	data, err := b.fileManager.ReadFile(xctestrunPth)
 	if err != nil {
 		return err
 	}
 
-	newC := bytes.Replace(data, []byte("/private__TESTROOT__"), []byte("__TESTROOT__"), -1)
+	privateTestRoot := "/private" + xctestrun.TestRootPlaceholder
+
+	testRun, err := xctestrun.Parse(data)
+	if err != nil {
+		b.logger.Warnf("%s: %s, replacing %s in its raw content", filepath.Base(xctestrunPth), err, privateTestRoot)
+
+		newC := bytes.Replace(data, []byte(privateTestRoot), []byte(xctestrun.TestRootPlaceholder), -1)
+		if bytes.Equal(newC, data) {
+			return nil
+		}
+		return b.fileManager.WriteFile(xctestrunPth, newC, 0666)
+	}
+
+	changed := false
+	testRun.RewritePaths(func(pth string) string {
+		newPth := strings.Replace(pth, privateTestRoot, xctestrun.TestRootPlaceholder, -1)
+		if newPth != pth {
+			changed = true
+		}
+		return newPth
+	})
+	if !changed {
+		return nil
+	}
 
-	return b.fileManager.WriteFile(xctestrunPth, newC, 0666)
+	return b.writeXctestrun(xctestrunPth, testRun)
+}
+
+func (b XcodebuildBuilder) readXctestrun(xctestrunPth string) (*xctestrun.File, error) {
+	data, err := b.fileManager.ReadFile(xctestrunPth)
+	if err != nil {
+		return nil, err
+	}
+
+	return xctestrun.Parse(data)
+}
+
+func (b XcodebuildBuilder) validateXctestrun(xctestrunPth string) error {
+	testRun, err := b.readXctestrun(xctestrunPth)
+	if err != nil {
+		return err
+	}
+
+	return testRun.Validate()
+}
+
+func (b XcodebuildBuilder) writeXctestrun(xctestrunPth string, testRun *xctestrun.File) error {
+	data, err := testRun.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to encode xctestrun: %w", err)
+	}
+
+	return b.fileManager.WriteFile(xctestrunPth, data, 0666)
 }
 
 func (b XcodebuildBuilder) exportXcodebuildLog(outputDir, xcodebuildLog string) error {
@@ -569,62 +1023,168 @@ func (b XcodebuildBuilder) exportXcodebuildLog(outputDir, xcodebuildLog string)
 	return nil
 }
 
-func (b XcodebuildBuilder) exportTestBundle(outputDir string, compressionLevel int, symroot string, xctestrunPths []string, defaultXctestrunPth string) error {
+func (b XcodebuildBuilder) exportTestBundle(opts ExportOpts) (string, error) {
 	// BITRISE_TEST_BUNDLE_PATH
-	if err := tools.ExportEnvironmentWithEnvman(testBundlePathEnvKey, symroot); err != nil {
-		return err
+	if err := tools.ExportEnvironmentWithEnvman(testBundlePathEnvKey, opts.SYMRoot); err != nil {
+		return "", err
 	}
-	b.logger.Donef("The test bundle directory is available in %s env: %s", testBundlePathEnvKey, symroot)
+	b.logger.Donef("The test bundle directory is available in %s env: %s", testBundlePathEnvKey, opts.SYMRoot)
 
-	// BITRISE_TEST_BUNDLE_ZIP_PATH
-	testBundleZipPth := filepath.Join(outputDir, "testbundle.zip")
+	var testBundleArchivePth string
+	if opts.ArchiveFormat != archiveFormatNone {
+		pth, err := b.archiveTestBundle(opts)
+		if err != nil {
+			return "", err
+		}
+		testBundleArchivePth = pth
+	}
 
-	entries, err := b.fileManager.ReadDir(symroot)
-	if err != nil {
-		return fmt.Errorf("failed to list SYMROOT entries: %w", err)
+	// BITRISE_XCTESTRUN_FILE_PATH
+	if len(opts.XctestrunPths) > 1 {
+		b.logger.Warnf("Multiple xctestrun files generated, exporting %s under %s, see %s for every xctestrun file", opts.DefaultXctestrunPth, xctestrunPathEnvKey, xctestrunPathListEnvKey)
+	}
+	if err := output.ExportOutputFile(opts.DefaultXctestrunPth, opts.DefaultXctestrunPth, xctestrunPathEnvKey); err != nil {
+		return "", err
 	}
+	b.logger.Donef("The built xctestrun file is available in %s env: %s", xctestrunPathEnvKey, opts.DefaultXctestrunPth)
+
+	return testBundleArchivePth, nil
+}
 
-	args := []string{"-r", fmt.Sprintf("-%d", compressionLevel), testBundleZipPth}
+// archiveTestBundle archives the test bundle in the selected format and packaging, and returns the (default) archive's path.
+// The zip specific outputs are only exported for the zip format.
+func (b XcodebuildBuilder) archiveTestBundle(opts ExportOpts) (string, error) {
+	format := archiver.Format(opts.ArchiveFormat)
+
+	var testBundleArchivePth string
+	if opts.Packaging == packagingPerXctestrun {
+		archivePthByXctestrun, err := b.packagePerXctestrun(opts)
+		if err != nil {
+			return "", err
+		}
 
-	// add all build folders to zip file:
-	//	+ Debug-iphonesimulator/
-	//	+ Debug-watchsimulator/
-	for _, builtTestsDir := range entries {
-		abspath := filepath.Join(symroot, builtTestsDir.Name())
-		if exists, err := b.pathChecker.IsDirExists(abspath); exists && err == nil {
-			args = append(args, builtTestsDir.Name())
+		// BITRISE_TEST_BUNDLE_ARCHIVE_PATH_LIST
+		archivePthList := strings.Join(sortedValues(archivePthByXctestrun), "|")
+		if err := tools.ExportEnvironmentWithEnvman(testBundleArchivePathListEnvKey, archivePthList); err != nil {
+			return "", err
+		}
+		b.logger.Donef("The test bundle archives (one per xctestrun file) are available in %s env: %s", testBundleArchivePathListEnvKey, archivePthList)
+
+		// BITRISE_TEST_BUNDLE_ZIP_PATH_LIST
+		if format == archiver.FormatZip {
+			if err := tools.ExportEnvironmentWithEnvman(testBundleZipPathListEnvKey, archivePthList); err != nil {
+				return "", err
+			}
+			b.logger.Donef("The zipped test bundles (one per xctestrun file) are available in %s env: %s", testBundleZipPathListEnvKey, archivePthList)
+		}
+
+		testBundleArchivePth = archivePthByXctestrun[opts.DefaultXctestrunPth]
+	} else if opts.Packaging == packagingPerDestination {
+		archivePthByDestination, err := b.packagePerDestination(opts)
+		if err != nil {
+			return "", err
+		}
+
+		// BITRISE_TEST_BUNDLE_ARCHIVE_PATH_LIST
+		archivePthList := strings.Join(sortedValues(archivePthByDestination), "|")
+		if err := tools.ExportEnvironmentWithEnvman(testBundleArchivePathListEnvKey, archivePthList); err != nil {
+			return "", err
+		}
+		b.logger.Donef("The test bundle archives (one per destination) are available in %s env: %s", testBundleArchivePathListEnvKey, archivePthList)
+
+		// BITRISE_TEST_BUNDLE_ZIP_PATH_LIST
+		if format == archiver.FormatZip {
+			if err := tools.ExportEnvironmentWithEnvman(testBundleZipPathListEnvKey, archivePthList); err != nil {
+				return "", err
+			}
+			b.logger.Donef("The zipped test bundles (one per destination) are available in %s env: %s", testBundleZipPathListEnvKey, archivePthList)
+		}
+
+		testBundleArchivePth = archivePthByDestination[xctestrunDestination(opts.DefaultXctestrunPth)]
+	} else {
+		testBundleArchivePth = filepath.Join(opts.OutputDir, "testbundle"+format.Extension())
+
+		var archiveEntries []string
+		if opts.Prune {
+			pruned, err := b.pruneTestBundle(opts.SYMRoot, opts.XctestrunPths, opts.PruneAllowList, opts.PruneDenyList)
+			if err != nil {
+				return "", fmt.Errorf("failed to prune test bundle: %w", err)
+			}
+			b.reportPrunedTestBundle(pruned)
+
+			archiveEntries = pruned.Entries
+		} else {
+			entries, err := b.fileManager.ReadDir(opts.SYMRoot)
+			if err != nil {
+				return "", fmt.Errorf("failed to list SYMROOT entries: %w", err)
+			}
+
+			// add all build folders to the archive:
+			//	+ Debug-iphonesimulator/
+			//	+ Debug-watchsimulator/
+			for _, builtTestsDir := range entries {
+				abspath := filepath.Join(opts.SYMRoot, builtTestsDir.Name())
+				if exists, err := b.pathChecker.IsDirExists(abspath); exists && err == nil {
+					archiveEntries = append(archiveEntries, builtTestsDir.Name())
+				}
+			}
+
+			for _, xctestrunPth := range opts.XctestrunPths {
+				archiveEntries = append(archiveEntries, filepath.Base(xctestrunPth))
+			}
+		}
+		archiveEntries = append(archiveEntries, xctestrunShardEntries(opts.XctestrunShardPths)...)
+
+		if err := b.archiveTestBundleEntries(opts.SYMRoot, archiveEntries, testBundleArchivePth, opts); err != nil {
+			return "", err
 		}
 	}
 
-	for _, xctestrunPth := range xctestrunPths {
-		args = append(args, filepath.Base(xctestrunPth))
+	// BITRISE_TEST_BUNDLE_ARCHIVE_PATH
+	if err := output.ExportOutputFile(testBundleArchivePth, testBundleArchivePth, testBundleArchivePathEnvKey); err != nil {
+		return "", err
 	}
+	b.logger.Donef("The test bundle archive is available in %s env: %s", testBundleArchivePathEnvKey, testBundleArchivePth)
 
-	factory := v2command.NewFactory(env.NewRepository())
-	zipCmd := factory.Create("zip", args, &v2command.Opts{
-		Dir: symroot,
-	})
-	b.logger.Debugf("$ %s", zipCmd.PrintableCommandArgs())
-	if out, err := zipCmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
-		var exerr *exec.ExitError
-		if errors.As(err, &exerr) {
-			return fmt.Errorf("%s failed: %s", zipCmd.PrintableCommandArgs(), out)
+	// BITRISE_TEST_BUNDLE_ZIP_PATH
+	if format == archiver.FormatZip {
+		if err := output.ExportOutputFile(testBundleArchivePth, testBundleArchivePth, testBundleZipPathEnvKey); err != nil {
+			return "", err
 		}
-		return fmt.Errorf("%s failed: %w", zipCmd.PrintableCommandArgs(), err)
+		b.logger.Donef("The zipped test bundle is available in %s env: %s", testBundleZipPathEnvKey, testBundleArchivePth)
 	}
-	if err := output.ExportOutputFile(testBundleZipPth, testBundleZipPth, testBundleZipPathEnvKey); err != nil {
-		return err
+
+	return testBundleArchivePth, nil
+}
+
+// archiveTestBundleEntries archives the given entries (relative to the build root) into archivePth.
+func (b XcodebuildBuilder) archiveTestBundleEntries(symRoot string, entries []string, archivePth string, opts ExportOpts) error {
+	var excludes []string
+	if opts.Prune {
+		excludes = opts.PruneDenyList
 	}
-	b.logger.Donef("The zipped test bundle is available in %s env: %s", testBundleZipPathEnvKey, testBundleZipPth)
 
-	// BITRISE_XCTESTRUN_FILE_PATH
-	if len(xctestrunPths) > 1 {
-		b.logger.Warnf("Multiple xctestrun files generated, exporting %s under BITRISE_XCTESTRUN_FILE_PATH", defaultXctestrunPth)
+	b.logger.Printf("Archiving %s into %s", strings.Join(entries, ", "), archivePth)
+	return b.archiver.Archive(symRoot, entries, archivePth, archiver.Opts{
+		Format:           archiver.Format(opts.ArchiveFormat),
+		CompressionLevel: opts.CompressionLevel,
+		Excludes:         excludes,
+		Reproducible:     opts.Reproducible,
+	})
+}
+
+// exportTestBundleHash exports the SHA-256 checksum of the test bundle archive.
+// The checksum only depends on the test bundle content if the archive was created with the reproducible option.
+func (b XcodebuildBuilder) exportTestBundleHash(testBundleArchivePth string) (string, error) {
+	checksum, err := fileSHA256(testBundleArchivePth)
+	if err != nil {
+		return "", err
 	}
-	if err := output.ExportOutputFile(defaultXctestrunPth, defaultXctestrunPth, xctestrunPathEnvKey); err != nil {
-		return err
+
+	if err := tools.ExportEnvironmentWithEnvman(testBundleHashEnvKey, checksum); err != nil {
+		return "", fmt.Errorf("failed to export %s: %w", testBundleHashEnvKey, err)
 	}
-	b.logger.Donef("The built xctestrun file is available in %s env: %s", xctestrunPathEnvKey, defaultXctestrunPth)
+	b.logger.Donef("The test bundle archive's SHA-256 checksum is available in %s env: %s", testBundleHashEnvKey, checksum)
 
-	return nil
+	return checksum, nil
 }
@@ -1,13 +1,16 @@
 package step
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/bitrise-io/go-utils/v2/pathutil"
 	"github.com/bitrise-io/go-xcode/xcodeproject/xcscheme"
 	"github.com/bitrise-steplib/steps-xcode-build-for-test/mocks"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -26,7 +29,7 @@ func Test_GivenIosProjectProducesOneXctestrun_WhenFindTestBundle_ThenReturnsTest
 	stepMocks.fileManager.On("ReadDir", mock.Anything).Return([]os.DirEntry{
 		createDirEntry("BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"),
 	}, nil)
-	stepMocks.fileManager.On("ReadFile", mock.Anything).Return([]byte{}, nil)
+	stepMocks.fileManager.On("ReadFile", mock.Anything).Return(xctestrunContent("BullsEyeTests"), nil)
 	stepMocks.fileManager.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	// When
@@ -40,9 +43,72 @@ func Test_GivenIosProjectProducesOneXctestrun_WhenFindTestBundle_ThenReturnsTest
 	require.NoError(t, err)
 	require.Equal(t, filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"), bundle.DefaultXctestrunPth)
 	require.Equal(t, []string{filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")}, bundle.XctestrunPths)
+	require.Equal(t, xctestrunsByTestPlan{
+		"FullTests": {"iphonesimulator15.5-arm64": filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")},
+	}, bundle.XctestrunPthsByTestPlan)
 	require.Equal(t, symRoot, bundle.SYMRoot)
 }
 
+func Test_GivenShardOfAnEarlierBuildInBuildRoot_WhenFindTestBundle_ThenShardIsIgnored(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	symRoot := "/symroot"
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Donef", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Debugf", mock.Anything, mock.Anything).Return()
+	stepMocks.fileManager.On("ReadDir", symRoot).Return([]os.DirEntry{
+		createDirEntry("BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"),
+		createDirEntry("BullsEye_FullTests_iphonesimulator15.5-arm64_shard-1-of-4.xctestrun"),
+	}, nil)
+	stepMocks.fileManager.On("ReadFile", mock.Anything).Return(xctestrunContent("BullsEyeTests"), nil)
+	stepMocks.fileManager.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	// When
+	bundle, err := step.findTestBundle(findTestBundleOpts{
+		SYMRoot:     symRoot,
+		ProjectPath: "BullsEye.xcworkspace",
+		Scheme:      "BullsEye",
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")}, bundle.XctestrunPths)
+	require.Equal(t, xctestrunsByTestPlan{
+		"FullTests": {"iphonesimulator15.5-arm64": filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")},
+	}, bundle.XctestrunPthsByTestPlan)
+}
+
+func Test_GivenUnsupportedXctestrun_WhenFindTestBundle_ThenMapsItByFileName(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	symRoot := "/symroot"
+	content := strings.Replace(string(xctestrunContent("BullsEyeTests")), "<integer>1</integer>", "<integer>3</integer>", 1)
+
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Donef", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	stepMocks.fileManager.On("ReadDir", symRoot).Return([]os.DirEntry{
+		createDirEntry("BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"),
+	}, nil)
+	stepMocks.fileManager.On("ReadFile", mock.Anything).Return([]byte(content), nil)
+
+	// When
+	bundle, err := step.findTestBundle(findTestBundleOpts{
+		SYMRoot:     symRoot,
+		ProjectPath: "BullsEye.xcworkspace",
+		Scheme:      "BullsEye",
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, xctestrunsByTestPlan{
+		"FullTests": {"iphonesimulator15.5-arm64": filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")},
+	}, bundle.XctestrunPthsByTestPlan)
+}
+
 func Test_GivenIosProjectProducesMultipleXctestrun_WhenFindTestBundle_ThenReturnsTestBundle(t *testing.T) {
 	// Given
 	step, stepMocks := createStepAndMocks()
@@ -60,7 +126,7 @@ func Test_GivenIosProjectProducesMultipleXctestrun_WhenFindTestBundle_ThenReturn
 		createDirEntry("BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun"),
 		createDirEntry("BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"),
 	}, nil)
-	stepMocks.fileManager.On("ReadFile", mock.Anything).Return([]byte{}, nil)
+	stepMocks.fileManager.On("ReadFile", mock.Anything).Return(xctestrunContent("BullsEyeTests"), nil)
 	stepMocks.fileManager.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	stepMocks.xcodeproject.On("Scheme", project, scheme).Return(&xcscheme.Scheme{
 		TestAction: xcscheme.TestAction{
@@ -92,12 +158,357 @@ func Test_GivenIosProjectProducesMultipleXctestrun_WhenFindTestBundle_ThenReturn
 	require.Equal(t, symRoot, bundle.SYMRoot)
 }
 
+func Test_GivenTestPlanNameIsSubstringOfAnOther_WhenFindTestBundle_ThenDefaultXctestrunMatchesTheExactTestPlan(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	project := "BullsEye.xcworkspace"
+	scheme := "BullsEye"
+	symRoot := "/symroot"
+
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Donef", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Donef", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	stepMocks.fileManager.On("ReadDir", symRoot).Return([]os.DirEntry{
+		createDirEntry("BullsEye_UI_iphonesimulator15.5-arm64.xctestrun"),
+		createDirEntry("BullsEye_Tests_UI_iphonesimulator15.5-arm64.xctestrun"),
+	}, nil)
+	stepMocks.fileManager.On("ReadFile", mock.Anything).Return(xctestrunContent("BullsEyeTests"), nil)
+	stepMocks.fileManager.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	stepMocks.xcodeproject.On("Scheme", project, scheme).Return(&xcscheme.Scheme{
+		TestAction: xcscheme.TestAction{
+			TestPlans: &xcscheme.TestPlans{
+				TestPlanReferences: []xcscheme.TestPlanReference{
+					{Reference: "container:UI.xctestplan", Default: "YES"},
+					{Reference: "container:Tests_UI.xctestplan"},
+				},
+			},
+		},
+	}, nil)
+
+	// When
+	bundle, err := step.findTestBundle(findTestBundleOpts{
+		SYMRoot:     symRoot,
+		ProjectPath: project,
+		Scheme:      scheme,
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(symRoot, "BullsEye_UI_iphonesimulator15.5-arm64.xctestrun"), bundle.DefaultXctestrunPth)
+	require.Equal(t, xctestrunsByTestPlan{
+		"UI":       {"iphonesimulator15.5-arm64": filepath.Join(symRoot, "BullsEye_UI_iphonesimulator15.5-arm64.xctestrun")},
+		"Tests_UI": {"iphonesimulator15.5-arm64": filepath.Join(symRoot, "BullsEye_Tests_UI_iphonesimulator15.5-arm64.xctestrun")},
+	}, bundle.XctestrunPthsByTestPlan)
+}
+
+func Test_GivenRequestedTestPlans_WhenFindTestBundle_ThenKeepsTheirXctestruns(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	symRoot := "/symroot"
+	fullTestsPth := filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")
+
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Donef", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Donef", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	stepMocks.fileManager.On("ReadDir", symRoot).Return([]os.DirEntry{
+		createDirEntry("BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"),
+		createDirEntry("BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun"),
+		createDirEntry("BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun"),
+	}, nil)
+	stepMocks.fileManager.On("ReadFile", mock.Anything).Return(xctestrunContent("BullsEyeTests"), nil)
+	stepMocks.fileManager.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	stepMocks.fileManager.On("Remove", fullTestsPth).Return(nil)
+
+	// When
+	bundle, err := step.findTestBundle(findTestBundleOpts{
+		SYMRoot:     symRoot,
+		ProjectPath: "BullsEye.xcworkspace",
+		Scheme:      "BullsEye",
+		TestPlans:   []string{"UnitTests", "UITests"},
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(symRoot, "BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun"),
+		filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun"),
+	}, bundle.XctestrunPths)
+	require.Equal(t, filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun"), bundle.DefaultXctestrunPth)
+	stepMocks.xcodeproject.AssertNotCalled(t, "Scheme", mock.Anything, mock.Anything)
+	stepMocks.fileManager.AssertCalled(t, "Remove", fullTestsPth)
+}
+
+func Test_GivenMissingUITestRunner_WhenCheckTestBundleIntegrity_ThenReportsMissingProducts(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	symRoot := "/symroot"
+	xctestrunPth := filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(data, nil)
+	stepMocks.pathChecker.On("IsPathExists", mock.Anything).Return(func(pth string) (bool, error) {
+		return !strings.Contains(pth, "BullsEyeUITests-Runner.app"), nil
+	})
+
+	// When
+	reports, err := step.checkTestBundleIntegrity(testBundle{
+		XctestrunPths: []string{xctestrunPth},
+		SYMRoot:       symRoot,
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []testTargetIntegrity{
+		{
+			Xctestrun:  "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun",
+			TestTarget: "BullsEyeTests",
+		},
+		{
+			Xctestrun:  "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun",
+			TestTarget: "BullsEyeUITests",
+			Missing: []missingProduct{
+				{Key: "TestHostPath", Path: "/symroot/Debug-iphonesimulator/BullsEyeUITests-Runner.app"},
+				{Key: "TestBundlePath", Path: "/symroot/Debug-iphonesimulator/BullsEyeUITests-Runner.app/PlugIns/BullsEyeUITests.xctest"},
+				{Key: "DependentProductPaths", Path: "/symroot/Debug-iphonesimulator/BullsEyeUITests-Runner.app/PlugIns/BullsEyeUITests.xctest"},
+			},
+		},
+	}, reports)
+}
+
+func Test_GivenUnsupportedXctestrun_WhenCheckTestBundleIntegrity_ThenReportsItAsUnchecked(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	xctestrunPth := "/symroot/BullsEye_iphonesimulator15.5-arm64.xctestrun"
+	content := strings.Replace(string(xctestrunContent("BullsEyeTests")), "<integer>1</integer>", "<integer>3</integer>", 1)
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return([]byte(content), nil)
+
+	// When
+	reports, err := step.checkTestBundleIntegrity(testBundle{
+		XctestrunPths: []string{xctestrunPth},
+		SYMRoot:       "/symroot",
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []testTargetIntegrity{
+		{
+			Xctestrun: "BullsEye_iphonesimulator15.5-arm64.xctestrun",
+			Unchecked: "unsupported xctestrun FormatVersion: 3",
+		},
+	}, reports)
+}
+
+func Test_GivenMissingProducts_WhenReportTestBundleIntegrity_ThenFailsOnlyInFailMode(t *testing.T) {
+	reports := []testTargetIntegrity{
+		{
+			Xctestrun:  "BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun",
+			TestTarget: "BullsEyeUITests",
+			Missing:    []missingProduct{{Key: "TestHostPath", Path: "/symroot/Debug-iphonesimulator/BullsEyeUITests-Runner.app"}},
+		},
+	}
+
+	for _, mode := range []string{integrityCheckFail, integrityCheckWarn} {
+		t.Run(mode, func(t *testing.T) {
+			// Given
+			step, stepMocks := createStepAndMocks()
+			stepMocks.logger.On("Println").Return()
+			stepMocks.logger.On("Infof", mock.Anything).Return()
+			stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+			stepMocks.logger.On("Warnf", mock.Anything, mock.Anything, mock.Anything).Return()
+			stepMocks.logger.On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+			// When
+			err := step.reportTestBundleIntegrity(reports, mode)
+
+			// Then
+			if mode == integrityCheckFail {
+				require.EqualError(t, err, "test bundle is incomplete: 1 product(s) referenced by the xctestrun file(s) are missing")
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_GivenTestBundle_WhenCreateTestBundleManifest_ThenDescribesXctestrunsAndProducts(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	symRoot := t.TempDir()
+	xctestrunPth := filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+
+	for bundlePth, bundleID := range map[string]string{
+		"Debug-iphonesimulator/BullsEye.app":                                              "io.bitrise.BullsEye",
+		"Debug-iphonesimulator/BullsEye.app/PlugIns/BullsEyeTests.xctest":                 "io.bitrise.BullsEyeTests",
+		"Debug-iphonesimulator/BullsEyeUITests-Runner.app":                                "io.bitrise.BullsEyeUITests.xctrunner",
+		"Debug-iphonesimulator/BullsEyeUITests-Runner.app/PlugIns/BullsEyeUITests.xctest": "io.bitrise.BullsEyeUITests",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(symRoot, bundlePth), 0755))
+		infoPlist := fmt.Sprintf(`<plist version="1.0"><dict><key>CFBundleIdentifier</key><string>%s</string></dict></plist>`, bundleID)
+		require.NoError(t, os.WriteFile(filepath.Join(symRoot, bundlePth, "Info.plist"), []byte(infoPlist), 0644))
+	}
+
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(data, nil)
+
+	// When
+	manifest, err := step.createTestBundleManifest(Config{
+		ProjectPath:   "BullsEye.xcworkspace",
+		Scheme:        "BullsEye",
+		Configuration: "Debug",
+		Destinations:  []string{"generic/platform=iOS Simulator"},
+		XcodeVersion:  "15.0 (15A240d)",
+	}, testBundle{
+		XctestrunPths:           []string{xctestrunPth},
+		XctestrunPthsByTestPlan: xctestrunsByTestPlan{"FullTests": {"iphonesimulator15.5-arm64": xctestrunPth}},
+		SYMRoot:                 symRoot,
+	}, []string{filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun")})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, testBundleManifest{
+		Scheme:        "BullsEye",
+		Configuration: "Debug",
+		Destinations:  []string{"generic/platform=iOS Simulator"},
+		XcodeVersion:  "15.0 (15A240d)",
+		TestPlans: []manifestTestPlan{
+			{Name: "FullTests", IsDefault: true},
+		},
+		Xctestruns: []manifestXctestrun{
+			{
+				Path:        "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun",
+				TestPlan:    "FullTests",
+				Destination: "iphonesimulator15.5-arm64",
+				TestTargets: []manifestTestTarget{
+					{
+						Name:       "BullsEyeTests",
+						TestHost:   "Debug-iphonesimulator/BullsEye.app",
+						TestBundle: "Debug-iphonesimulator/BullsEye.app/PlugIns/BullsEyeTests.xctest",
+					},
+					{
+						Name:           "BullsEyeUITests",
+						IsUITestBundle: true,
+						TestHost:       "Debug-iphonesimulator/BullsEyeUITests-Runner.app",
+						TestBundle:     "Debug-iphonesimulator/BullsEyeUITests-Runner.app/PlugIns/BullsEyeUITests.xctest",
+						UITargetApp:    "Debug-iphonesimulator/BullsEye.app",
+					},
+				},
+			},
+		},
+		XctestrunShards: []string{"BullsEye_FullTests_iphonesimulator15.5-arm64_shard-1-of-2.xctestrun"},
+		Products: []manifestProduct{
+			{Path: "Debug-iphonesimulator/BullsEye.app", BundleID: "io.bitrise.BullsEye"},
+			{Path: "Debug-iphonesimulator/BullsEye.app/PlugIns/BullsEyeTests.xctest", BundleID: "io.bitrise.BullsEyeTests"},
+			{Path: "Debug-iphonesimulator/BullsEyeUITests-Runner.app", BundleID: "io.bitrise.BullsEyeUITests.xctrunner"},
+			{Path: "Debug-iphonesimulator/BullsEyeUITests-Runner.app/PlugIns/BullsEyeUITests.xctest", BundleID: "io.bitrise.BullsEyeUITests"},
+		},
+	}, manifest)
+}
+
+func Test_GivenXctestrunWithPrivateTestRoot_WhenFixTestRoot_ThenRewritesProductPaths(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	xctestrunPth := "/tmp/BullsEye_iphonesimulator15.5-arm64.xctestrun"
+	content := strings.Replace(string(xctestrunContent("BullsEyeTests")), "__TESTROOT__", "/private__TESTROOT__", -1)
+
+	var written []byte
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return([]byte(content), nil)
+	stepMocks.fileManager.On("WriteFile", xctestrunPth, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]byte)
+	}).Return(nil)
+
+	// When
+	err := step.fixTestRoot(xctestrunPth)
+
+	// Then
+	require.NoError(t, err)
+	require.NotContains(t, string(written), "/private__TESTROOT__")
+
+	testRun, err := xctestrun.Parse(written)
+	require.NoError(t, err)
+	require.Equal(t, "__TESTROOT__/Debug-iphonesimulator/BullsEye.app", testRun.TestTargets()[0].TestHostPath)
+	require.Equal(t, []string{"__TESTROOT__/Debug-iphonesimulator/BullsEye.app"}, testRun.TestTargets()[0].DependentProductPaths)
+}
+
+func Test_GivenFormatVersion2XctestrunWithPrivateTestRoot_WhenFixTestRoot_ThenRewritesCodeCoverageProductPaths(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	content := strings.Replace(string(data), "__TESTROOT__", "/private__TESTROOT__", -1)
+
+	xctestrunPth := "/tmp/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	var written []byte
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return([]byte(content), nil)
+	stepMocks.fileManager.On("WriteFile", xctestrunPth, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]byte)
+	}).Return(nil)
+
+	// When
+	err = step.fixTestRoot(xctestrunPth)
+
+	// Then
+	require.NoError(t, err)
+	require.NotContains(t, string(written), "/private__TESTROOT__")
+	require.Contains(t, string(written), "<string>__TESTROOT__/Debug-iphonesimulator/BullsEye.app/BullsEye</string>")
+}
+
+func Test_GivenXctestrunWithoutPrivateTestRoot_WhenFixTestRoot_ThenFileIsNotWritten(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	xctestrunPth := "/tmp/BullsEye_iphonesimulator15.5-arm64.xctestrun"
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(xctestrunContent("BullsEyeTests"), nil)
+
+	// When
+	err := step.fixTestRoot(xctestrunPth)
+
+	// Then
+	require.NoError(t, err)
+	stepMocks.fileManager.AssertNotCalled(t, "WriteFile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_GivenUnsupportedXctestrun_WhenFixTestRoot_ThenReplacesRawContent(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+
+	xctestrunPth := "/tmp/BullsEye_iphonesimulator15.5-arm64.xctestrun"
+	content := strings.Replace(string(xctestrunContent("BullsEyeTests")), "__TESTROOT__", "/private__TESTROOT__", -1)
+	content = strings.Replace(content, "<integer>1</integer>", "<integer>3</integer>", 1)
+
+	var written []byte
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return([]byte(content), nil)
+	stepMocks.fileManager.On("WriteFile", xctestrunPth, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]byte)
+	}).Return(nil)
+
+	// When
+	err := step.fixTestRoot(xctestrunPth)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, strings.Replace(content, "/private__TESTROOT__", "__TESTROOT__", -1), string(written))
+	stepMocks.logger.AssertCalled(t, "Warnf", mock.Anything, mock.Anything)
+}
+
 type testingMocks struct {
-	logger       *mocks.Logger
-	xcodeproject *mocks.XcodeProject
-	pathChecker  *mocks.PathChecker
-	pathModifier *pathutil.PathModifier
-	fileManager  *mocks.FileManager
+	logger                  *mocks.Logger
+	xcodeproject            *mocks.XcodeProject
+	pathChecker             *mocks.PathChecker
+	pathModifier            *pathutil.PathModifier
+	fileManager             *mocks.FileManager
+	archiver                *mocks.Archiver
+	sleeper                 *mocks.Sleeper
+	packageResolutionRunner *mocks.XCCommandRunner
 }
 
 func createStepAndMocks() (XcodebuildBuilder, testingMocks) {
@@ -111,6 +522,9 @@ func createStepAndMocks() (XcodebuildBuilder, testingMocks) {
 	xcodeVersionReader := new(mocks.XCVersionReader)
 	pathProvider := new(mocks.PathProvider)
 	cmdFactory := new(mocks.CommandFactory)
+	archiver := new(mocks.Archiver)
+	sleeper := new(mocks.Sleeper)
+	packageResolutionRunner := new(mocks.XCCommandRunner)
 
 	step := NewXcodebuildBuilder(
 		xcodeCommandRunner,
@@ -123,14 +537,20 @@ func createStepAndMocks() (XcodebuildBuilder, testingMocks) {
 		fileManager,
 		logger,
 		cmdFactory,
+		archiver,
+		sleeper,
+		packageResolutionRunner,
 	)
 
 	mocks := testingMocks{
-		logger:       logger,
-		xcodeproject: xcodeproject,
-		pathChecker:  pathChecker,
-		pathModifier: &pathModifier,
-		fileManager:  fileManager,
+		logger:                  logger,
+		xcodeproject:            xcodeproject,
+		pathChecker:             pathChecker,
+		pathModifier:            &pathModifier,
+		fileManager:             fileManager,
+		archiver:                archiver,
+		sleeper:                 sleeper,
+		packageResolutionRunner: packageResolutionRunner,
 	}
 
 	return step, mocks
@@ -160,3 +580,34 @@ func (e simpleDirEntry) Info() (os.FileInfo, error) {
 func createDirEntry(pth string) os.DirEntry {
 	return simpleDirEntry{name: pth}
 }
+
+// xctestrunContent returns a minimal FormatVersion 1 xctestrun with a single app hosted test target.
+func xctestrunContent(testTarget string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>%[1]s</key>
+	<dict>
+		<key>BlueprintName</key>
+		<string>%[1]s</string>
+		<key>DependentProductPaths</key>
+		<array>
+			<string>__TESTROOT__/Debug-iphonesimulator/BullsEye.app</string>
+		</array>
+		<key>IsAppHostedTestBundle</key>
+		<true/>
+		<key>TestBundlePath</key>
+		<string>__TESTHOST__/PlugIns/%[1]s.xctest</string>
+		<key>TestHostPath</key>
+		<string>__TESTROOT__/Debug-iphonesimulator/BullsEye.app</string>
+	</dict>
+	<key>__xctestrun_metadata__</key>
+	<dict>
+		<key>FormatVersion</key>
+		<integer>1</integer>
+	</dict>
+</dict>
+</plist>
+`, testTarget))
+}
@@ -0,0 +1,116 @@
+package step
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/bitrise-io/go-steputils/output"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/testdiscovery"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xcbundle"
+)
+
+const (
+	testInventoryPathEnvKey = "BITRISE_TEST_INVENTORY_PATH"
+	testInventoryBaseName   = "test-inventory.json"
+)
+
+// testInventory lists the tests compiled into the test bundles, test bundle paths are relative to the test bundle directory (SYMROOT).
+type testInventory struct {
+	TestTargets []inventoryTestTarget `json:"test_targets"`
+}
+
+type inventoryTestTarget struct {
+	Name       string `json:"name"`
+	TestBundle string `json:"test_bundle"`
+	// Tests are the test identifiers in the format of xcodebuild's -only-testing option: Target/Class/method.
+	Tests []string `json:"tests"`
+}
+
+// createTestInventory discovers the tests of every test bundle referenced by the xctestrun files.
+// Test bundles shared by multiple xctestrun files are listed once.
+func (b XcodebuildBuilder) createTestInventory(symRoot string, xctestrunPths []string) (testInventory, error) {
+	inventory := testInventory{TestTargets: []inventoryTestTarget{}}
+	discovered := map[string]bool{}
+	for _, xctestrunPth := range xctestrunPths {
+		testRun, err := b.readXctestrun(xctestrunPth)
+		if err != nil {
+			return testInventory{}, fmt.Errorf("failed to read %s: %w", xctestrunPth, err)
+		}
+
+		for _, target := range testRun.TestTargets() {
+			if !isInTestBundle(target.TestBundlePath) {
+				continue
+			}
+
+			testBundlePth := target.ResolvePath(target.TestBundlePath, symRoot)
+			if discovered[testBundlePth] {
+				continue
+			}
+			discovered[testBundlePth] = true
+
+			tests, err := discoverTests(target.BlueprintName, testBundlePth)
+			if err != nil {
+				b.logger.Warnf("Failed to discover the tests of %s: %s", target.BlueprintName, err)
+				continue
+			}
+			if len(tests) == 0 {
+				b.logger.Warnf("No test found in %s, make sure the test bundle is not stripped", filepath.Base(testBundlePth))
+			}
+
+			inventoryTarget := inventoryTestTarget{
+				Name:       target.BlueprintName,
+				TestBundle: relativeToSYMRoot(symRoot, testBundlePth),
+				Tests:      []string{},
+			}
+			for _, test := range tests {
+				inventoryTarget.Tests = append(inventoryTarget.Tests, test.Identifier())
+			}
+			inventory.TestTargets = append(inventory.TestTargets, inventoryTarget)
+		}
+	}
+	sort.Slice(inventory.TestTargets, func(i, j int) bool {
+		return inventory.TestTargets[i].TestBundle < inventory.TestTargets[j].TestBundle
+	})
+
+	return inventory, nil
+}
+
+func discoverTests(target, testBundlePth string) ([]testdiscovery.Test, error) {
+	bundle, err := xcbundle.Open(testBundlePth)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.ExecutablePath == "" {
+		return nil, fmt.Errorf("%s has no executable", testBundlePth)
+	}
+
+	return testdiscovery.Discover(target, bundle.ExecutablePath)
+}
+
+func (b XcodebuildBuilder) exportTestInventory(opts ExportOpts) error {
+	inventory, err := b.createTestInventory(opts.SYMRoot, opts.XctestrunPths)
+	if err != nil {
+		return err
+	}
+
+	testCount := 0
+	for _, target := range inventory.TestTargets {
+		testCount += len(target.Tests)
+	}
+	b.logger.Printf("Discovered %d test(s) in %d test bundle(s)", testCount, len(inventory.TestTargets))
+
+	content, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode test inventory: %w", err)
+	}
+
+	inventoryPth := filepath.Join(opts.OutputDir, testInventoryBaseName)
+	if err := output.ExportOutputFileContent(string(content), inventoryPth, testInventoryPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", testInventoryPathEnvKey, err)
+	}
+	b.logger.Donef("The test inventory is available in %s env: %s", testInventoryPathEnvKey, inventoryPth)
+
+	return nil
+}
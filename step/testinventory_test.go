@@ -0,0 +1,65 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenTestBundleSharedByXctestruns_WhenCreateTestInventory_ThenListsDiscoveredTestsOnce(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	symRoot := t.TempDir()
+	testBundlePth := filepath.Join(symRoot, "Debug-iphonesimulator", "BullsEye.app", "PlugIns", "BullsEyeTests.xctest")
+	require.NoError(t, os.MkdirAll(testBundlePth, 0755))
+	for _, name := range []string{"Info.plist", "BullsEyeTests"} {
+		content, err := os.ReadFile(filepath.Join("..", "testdiscovery", "testdata", "BullsEyeTests.xctest", name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(testBundlePth, name), content, 0755))
+	}
+
+	unitTestsPth := filepath.Join(symRoot, "BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun")
+	fullTestsPth := filepath.Join(symRoot, "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun")
+	stepMocks.fileManager.On("ReadFile", mock.Anything).Return(xctestrunContent("BullsEyeTests"), nil)
+
+	// When
+	inventory, err := step.createTestInventory(symRoot, []string{unitTestsPth, fullTestsPth})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, testInventory{
+		TestTargets: []inventoryTestTarget{
+			{
+				Name:       "BullsEyeTests",
+				TestBundle: "Debug-iphonesimulator/BullsEye.app/PlugIns/BullsEyeTests.xctest",
+				Tests: []string{
+					"BullsEyeTests/BullsEyeObjCTests/testLegacyScoring",
+					"BullsEyeTests/BullsEyeTests/testAsyncHighScores",
+					"BullsEyeTests/BullsEyeTests/testScoring",
+					"BullsEyeTests/ScoreTests/testPerfectHit",
+				},
+			},
+		},
+	}, inventory)
+}
+
+func Test_GivenMissingTestBundle_WhenCreateTestInventory_ThenWarnsAndSkipsTarget(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	xctestrunPth := "/symroot/BullsEye.xctestrun"
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(xctestrunContent("BullsEyeTests"), nil)
+
+	// When
+	inventory, err := step.createTestInventory("/symroot", []string{xctestrunPth})
+
+	// Then
+	require.NoError(t, err)
+	require.Empty(t, inventory.TestTargets)
+	stepMocks.logger.AssertNumberOfCalls(t, "Warnf", 1)
+}
@@ -0,0 +1,234 @@
+package step
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcscheme"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
+)
+
+// testSelection lists the test identifiers in the format of xcodebuild's -only-testing and -skip-testing options: Target[/Class[/method]].
+type testSelection struct {
+	OnlyTesting []string
+	SkipTesting []string
+}
+
+func (s testSelection) isEmpty() bool {
+	return len(s.OnlyTesting) == 0 && len(s.SkipTesting) == 0
+}
+
+// parseTestIdentifiers collects the test identifiers of an input value (newline or | separated) and
+// of an optional file (one identifier per line, lines starting with # are comments).
+func parseTestIdentifiers(value, filePth string) ([]string, error) {
+	identifiers := parseList(value)
+	if filePth != "" {
+		content, err := os.ReadFile(filePth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filePth, err)
+		}
+		for _, line := range parseLines(string(content)) {
+			if !strings.HasPrefix(line, "#") {
+				identifiers = append(identifiers, line)
+			}
+		}
+	}
+
+	for _, identifier := range identifiers {
+		parts := strings.Split(identifier, "/")
+		valid := len(parts) <= 3
+		for _, part := range parts {
+			valid = valid && part != ""
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid test identifier (%s), use the Target[/Class[/method]] format", identifier)
+		}
+	}
+
+	return identifiers, nil
+}
+
+// validateTestSelection checks that the test target of every identifier is a testable of the scheme.
+// Schemes using Test Plans have no Testables, their test targets are checked against the test targets of the xctestrun files.
+func validateTestSelection(selection testSelection, scheme *xcscheme.Scheme, xctestrunTargets map[string]bool) error {
+	testables := map[string]bool{}
+	if scheme != nil {
+		for _, testable := range scheme.TestAction.Testables {
+			testables[testable.BuildableReference.BlueprintName] = true
+		}
+	}
+	if len(testables) == 0 {
+		testables = xctestrunTargets
+	}
+
+	var unknown []string
+	for _, identifier := range append(append([]string{}, selection.OnlyTesting...), selection.SkipTesting...) {
+		target, _, _ := strings.Cut(identifier, "/")
+		if !testables[target] {
+			unknown = append(unknown, identifier)
+		}
+	}
+	if len(unknown) > 0 {
+		var names []string
+		for name := range testables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("test identifier(s) not matching any testable: %s (available testables: %s)", strings.Join(unknown, ", "), strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// applyTestSelection writes the test selection into the OnlyTestIdentifiers and SkipTestIdentifiers of the matching test targets.
+// Like xcodebuild's -only-testing option, the only testing identifiers remove the test targets they don't mention,
+// and replace the OnlyTestIdentifiers of the mentioned targets. Skipped test targets are removed, skipped classes and methods are added to the SkipTestIdentifiers.
+// The xctestrun files without any test target left (for example the xctestrun of a test plan whose only target is skipped) are dropped from the test bundle,
+// the selection fails only if no xctestrun file has any test target left.
+// The xctestrun files which can not be parsed are kept unchanged.
+func (b XcodebuildBuilder) applyTestSelection(bundle testBundle, selection testSelection, scheme *xcscheme.Scheme) (testBundle, error) {
+	if selection.isEmpty() {
+		return bundle, nil
+	}
+	xctestrunPths := bundle.XctestrunPths
+
+	b.logger.Println()
+	b.logger.Infof("Applying test selection")
+
+	testRuns := map[string]*xctestrun.File{}
+	xctestrunTargets := map[string]bool{}
+	for _, xctestrunPth := range xctestrunPths {
+		testRun, err := b.readXctestrun(xctestrunPth)
+		if err != nil {
+			b.logger.Warnf("Failed to read %s, the test selection is not applied to it: %s", xctestrunPth, err)
+			continue
+		}
+		testRuns[xctestrunPth] = testRun
+		for _, target := range testRun.TestTargets() {
+			xctestrunTargets[target.BlueprintName] = true
+		}
+	}
+
+	if err := validateTestSelection(selection, scheme, xctestrunTargets); err != nil {
+		return testBundle{}, err
+	}
+
+	onlyTesting := groupTestIdentifiers(selection.OnlyTesting)
+	skipTesting := groupTestIdentifiers(selection.SkipTesting)
+	for _, identifiers := range []map[string][]string{onlyTesting, skipTesting} {
+		for target := range identifiers {
+			if !xctestrunTargets[target] {
+				b.logger.Warnf("%s is not built into any xctestrun file, its test identifiers have no effect", target)
+			}
+		}
+	}
+
+	dropped := map[string]bool{}
+	for _, xctestrunPth := range xctestrunPths {
+		testRun, ok := testRuns[xctestrunPth]
+		if !ok {
+			continue
+		}
+
+		var configurations []*xctestrun.TestConfiguration
+		for _, configuration := range testRun.TestConfigurations {
+			var targets []*xctestrun.TestTarget
+			for _, target := range configuration.TestTargets {
+				if !selectTestTarget(target, onlyTesting, skipTesting) {
+					continue
+				}
+				targets = append(targets, target)
+			}
+
+			if len(targets) > 0 {
+				configuration.TestTargets = targets
+				configurations = append(configurations, configuration)
+			}
+		}
+		if len(configurations) == 0 {
+			b.logger.Warnf("%s: no test target left after applying the test selection, dropping it from the test bundle", xctestrunPth)
+			dropped[xctestrunPth] = true
+			continue
+		}
+		testRun.TestConfigurations = configurations
+
+		if err := b.writeXctestrun(xctestrunPth, testRun); err != nil {
+			return testBundle{}, err
+		}
+
+		var names []string
+		for _, target := range testRun.TestTargets() {
+			names = append(names, target.BlueprintName)
+		}
+		b.logger.Printf("%s: %s", xctestrunPth, strings.Join(names, ", "))
+	}
+
+	if len(dropped) == len(xctestrunPths) {
+		return testBundle{}, fmt.Errorf("no test target left in any xctestrun file after applying the test selection")
+	}
+	if len(dropped) == 0 {
+		return bundle, nil
+	}
+
+	// the dropped xctestrun files are removed from the build root, so that the exported test bundle can't run the excluded tests
+	for _, xctestrunPth := range xctestrunPths {
+		if dropped[xctestrunPth] {
+			b.removeXctestrun(xctestrunPth)
+		}
+	}
+
+	droppedDefault := dropped[bundle.DefaultXctestrunPth]
+	bundle = dropXctestruns(bundle, dropped)
+	if droppedDefault {
+		b.logger.Warnf("The default xctestrun file has no test target left, using %s as the default xctestrun file", bundle.DefaultXctestrunPth)
+	}
+	return bundle, nil
+}
+
+// selectTestTarget updates the test identifiers of the target and reports whether the target is kept.
+func selectTestTarget(target *xctestrun.TestTarget, onlyTesting, skipTesting map[string][]string) bool {
+	if len(onlyTesting) > 0 {
+		identifiers, ok := onlyTesting[target.BlueprintName]
+		if !ok {
+			return false
+		}
+		if len(identifiers) > 0 {
+			target.OnlyTestIdentifiers = identifiers
+		}
+	}
+
+	if identifiers, ok := skipTesting[target.BlueprintName]; ok {
+		if len(identifiers) == 0 {
+			return false
+		}
+		for _, identifier := range identifiers {
+			if !sliceutil.IsStringInSlice(identifier, target.SkipTestIdentifiers) {
+				target.SkipTestIdentifiers = append(target.SkipTestIdentifiers, identifier)
+			}
+		}
+	}
+
+	return true
+}
+
+// groupTestIdentifiers groups the Class[/method] identifiers by test target,
+// a target identifier (selecting the whole target) is grouped as an empty list.
+func groupTestIdentifiers(identifiers []string) map[string][]string {
+	grouped := map[string][]string{}
+	wholeTargets := map[string]bool{}
+	for _, identifier := range identifiers {
+		target, classAndMethod, ok := strings.Cut(identifier, "/")
+		if !ok {
+			wholeTargets[target] = true
+			grouped[target] = nil
+			continue
+		}
+		if !wholeTargets[target] && !sliceutil.IsStringInSlice(classAndMethod, grouped[target]) {
+			grouped[target] = append(grouped[target], classAndMethod)
+		}
+	}
+	return grouped
+}
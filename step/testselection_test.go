@@ -0,0 +1,235 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcscheme"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseTestIdentifiers(t *testing.T) {
+	quarantinePth := filepath.Join(t.TempDir(), "quarantine.txt")
+	require.NoError(t, os.WriteFile(quarantinePth, []byte("# flaky since 2.3\nBullsEyeUITests/BullsEyeUITests/testGameStyleSwitch\n\nBullsEyeTests/ScoreTests\n"), 0644))
+
+	tests := []struct {
+		name    string
+		value   string
+		filePth string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "input value",
+			value: "BullsEyeTests|BullsEyeUITests/BullsEyeUITests\n",
+			want:  []string{"BullsEyeTests", "BullsEyeUITests/BullsEyeUITests"},
+		},
+		{
+			name:    "input value and file",
+			value:   "BullsEyeTests/BullsEyeTests/testScoring",
+			filePth: quarantinePth,
+			want:    []string{"BullsEyeTests/BullsEyeTests/testScoring", "BullsEyeUITests/BullsEyeUITests/testGameStyleSwitch", "BullsEyeTests/ScoreTests"},
+		},
+		{
+			name:    "missing file",
+			filePth: filepath.Join(t.TempDir(), "missing.txt"),
+			wantErr: true,
+		},
+		{
+			name:    "too many components",
+			value:   "BullsEyeTests/ScoreTests/testPerfectHit/extra",
+			wantErr: true,
+		},
+		{
+			name:    "empty component",
+			value:   "BullsEyeTests//testPerfectHit",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTestIdentifiers(tt.value, tt.filePth)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_validateTestSelection(t *testing.T) {
+	scheme := &xcscheme.Scheme{TestAction: xcscheme.TestAction{Testables: []xcscheme.TestableReference{
+		{BuildableReference: xcscheme.BuildableReference{BlueprintName: "BullsEyeTests"}},
+	}}}
+	xctestrunTargets := map[string]bool{"BullsEyeTests": true, "BullsEyeUITests": true}
+
+	tests := []struct {
+		name      string
+		selection testSelection
+		scheme    *xcscheme.Scheme
+		wantErr   bool
+	}{
+		{
+			name:      "testable of the scheme",
+			selection: testSelection{SkipTesting: []string{"BullsEyeTests/ScoreTests"}},
+			scheme:    scheme,
+		},
+		{
+			name:      "not a testable of the scheme",
+			selection: testSelection{OnlyTesting: []string{"BullsEyeUITests"}},
+			scheme:    scheme,
+			wantErr:   true,
+		},
+		{
+			name:      "scheme without testables",
+			selection: testSelection{OnlyTesting: []string{"BullsEyeUITests"}},
+			scheme:    &xcscheme.Scheme{},
+		},
+		{
+			name:      "unknown test target",
+			selection: testSelection{SkipTesting: []string{"BullsEyeSnapshotTests"}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTestSelection(tt.selection, tt.scheme, xctestrunTargets)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_GivenTestSelection_WhenApplyTestSelection_ThenXctestrunIdentifiersAreSet(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	content, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	xctestrunPth := "/test_bundle/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(content, nil)
+
+	var written []byte
+	stepMocks.fileManager.On("WriteFile", xctestrunPth, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]byte)
+	})
+
+	selection := testSelection{
+		OnlyTesting: []string{"BullsEyeTests/BullsEyeTests", "BullsEyeTests/ScoreTests/testPerfectHit"},
+		SkipTesting: []string{"BullsEyeTests/BullsEyeTests/testAsyncHighScores"},
+	}
+
+	// When
+	_, err = step.applyTestSelection(testBundle{XctestrunPths: []string{xctestrunPth}, DefaultXctestrunPth: xctestrunPth}, selection, nil)
+
+	// Then
+	require.NoError(t, err)
+
+	testRun, err := xctestrun.Parse(written)
+	require.NoError(t, err)
+	targets := testRun.TestTargets()
+	require.Len(t, targets, 1)
+	require.Equal(t, "BullsEyeTests", targets[0].BlueprintName)
+	require.Equal(t, []string{"BullsEyeTests", "ScoreTests/testPerfectHit"}, targets[0].OnlyTestIdentifiers)
+	require.Contains(t, targets[0].SkipTestIdentifiers, "BullsEyeTests/testAsyncHighScores")
+}
+
+func Test_GivenTestPlanWithOnlySkippedTarget_WhenApplyTestSelection_ThenItsXctestrunIsDropped(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+
+	unitTestsPth := "/test_bundle/BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun"
+	uiTestsPth := "/test_bundle/BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun"
+	stepMocks.fileManager.On("ReadFile", unitTestsPth).Return(xctestrunContent("BullsEyeTests"), nil)
+	stepMocks.fileManager.On("ReadFile", uiTestsPth).Return(xctestrunContent("BullsEyeUITests"), nil)
+	stepMocks.fileManager.On("WriteFile", unitTestsPth, mock.Anything, mock.Anything).Return(nil)
+	stepMocks.fileManager.On("Remove", uiTestsPth).Return(nil)
+
+	bundle := testBundle{
+		XctestrunPths:       []string{unitTestsPth, uiTestsPth},
+		DefaultXctestrunPth: uiTestsPth,
+		XctestrunPthsByTestPlan: xctestrunsByTestPlan{
+			"UnitTests": {"iphonesimulator15.5-arm64": unitTestsPth},
+			"UITests":   {"iphonesimulator15.5-arm64": uiTestsPth},
+		},
+	}
+
+	// When
+	got, err := step.applyTestSelection(bundle, testSelection{SkipTesting: []string{"BullsEyeUITests"}}, nil)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{unitTestsPth}, got.XctestrunPths)
+	require.Equal(t, unitTestsPth, got.DefaultXctestrunPth)
+	require.Equal(t, xctestrunsByTestPlan{
+		"UnitTests": {"iphonesimulator15.5-arm64": unitTestsPth},
+	}, got.XctestrunPthsByTestPlan)
+	stepMocks.fileManager.AssertNotCalled(t, "WriteFile", uiTestsPth, mock.Anything, mock.Anything)
+	stepMocks.fileManager.AssertCalled(t, "Remove", uiTestsPth)
+}
+
+func Test_GivenEveryTargetSkipped_WhenApplyTestSelection_ThenFails(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+
+	content, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	xctestrunPth := "/test_bundle/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(content, nil)
+
+	// When
+	_, err = step.applyTestSelection(testBundle{XctestrunPths: []string{xctestrunPth}, DefaultXctestrunPth: xctestrunPth}, testSelection{SkipTesting: []string{"BullsEyeTests", "BullsEyeUITests"}}, nil)
+
+	// Then
+	require.Error(t, err)
+	stepMocks.fileManager.AssertNotCalled(t, "WriteFile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_GivenUnsupportedXctestrun_WhenApplyTestSelection_ThenItIsKeptUnchanged(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	unitTestsPth := "/test_bundle/BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun"
+	unsupportedPth := "/test_bundle/BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun"
+	unsupported := strings.Replace(string(xctestrunContent("BullsEyeUITests")), "<integer>1</integer>", "<integer>3</integer>", 1)
+	stepMocks.fileManager.On("ReadFile", unitTestsPth).Return(xctestrunContent("BullsEyeTests"), nil)
+	stepMocks.fileManager.On("ReadFile", unsupportedPth).Return([]byte(unsupported), nil)
+	stepMocks.fileManager.On("Remove", unitTestsPth).Return(nil)
+
+	bundle := testBundle{
+		XctestrunPths:       []string{unitTestsPth, unsupportedPth},
+		DefaultXctestrunPth: unitTestsPth,
+	}
+
+	// When
+	got, err := step.applyTestSelection(bundle, testSelection{SkipTesting: []string{"BullsEyeTests"}}, nil)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{unsupportedPth}, got.XctestrunPths)
+	stepMocks.fileManager.AssertNotCalled(t, "WriteFile", mock.Anything, mock.Anything, mock.Anything)
+	stepMocks.fileManager.AssertNotCalled(t, "Remove", unsupportedPth)
+}
@@ -0,0 +1,172 @@
+package step
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bitrise-io/go-steputils/output"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcscheme"
+)
+
+const (
+	testTargetsPathEnvKey = "BITRISE_TEST_TARGETS_PATH"
+	testTargetsBaseName   = "test-targets.json"
+
+	testTargetKindUnit = "unit"
+	testTargetKindUI   = "ui"
+)
+
+// testTargetsReport lists the test targets of every xctestrun file, paths are relative to the test bundle directory (SYMROOT).
+type testTargetsReport struct {
+	Xctestruns []xctestrunTestTargets `json:"xctestruns"`
+}
+
+type xctestrunTestTargets struct {
+	Path        string           `json:"path"`
+	TestTargets []testTargetInfo `json:"test_targets"`
+}
+
+type testTargetInfo struct {
+	Name string `json:"name"`
+	// TestConfiguration is the Test Plan configuration of the target (FormatVersion 2 xctestrun files only).
+	TestConfiguration      string   `json:"test_configuration,omitempty"`
+	Kind                   string   `json:"kind"`
+	HostApp                string   `json:"host_app,omitempty"`
+	IsUITestBundle         bool     `json:"is_ui_test_bundle"`
+	OnlyTestIdentifiers    []string `json:"only_test_identifiers,omitempty"`
+	SkipTestIdentifiers    []string `json:"skip_test_identifiers,omitempty"`
+	ParallelizationEnabled bool     `json:"parallelization_enabled"`
+	// The scheme flags are only available if the target is listed in the scheme's Testables (schemes without a Test Plan).
+	InScheme               bool `json:"in_scheme"`
+	SkippedInScheme        bool `json:"skipped_in_scheme,omitempty"`
+	ParallelizableInScheme bool `json:"parallelizable_in_scheme,omitempty"`
+}
+
+// createTestTargetsReport collects the test targets of the xctestrun files, completed with the flags of the scheme's Testables.
+func (b XcodebuildBuilder) createTestTargetsReport(symRoot string, xctestrunPths []string, scheme *xcscheme.Scheme) (testTargetsReport, error) {
+	testables := map[string]xcscheme.TestableReference{}
+	if scheme != nil {
+		for _, testable := range scheme.TestAction.Testables {
+			testables[testable.BuildableReference.BlueprintName] = testable
+		}
+	}
+
+	report := testTargetsReport{Xctestruns: []xctestrunTestTargets{}}
+	for _, xctestrunPth := range xctestrunPths {
+		testRun, err := b.readXctestrun(xctestrunPth)
+		if err != nil {
+			return testTargetsReport{}, fmt.Errorf("failed to read %s: %w", xctestrunPth, err)
+		}
+
+		xctestrunTargets := xctestrunTestTargets{
+			Path:        relativeToSYMRoot(symRoot, xctestrunPth),
+			TestTargets: []testTargetInfo{},
+		}
+		for _, configuration := range testRun.TestConfigurations {
+			for _, target := range configuration.TestTargets {
+				info := testTargetInfo{
+					Name:                   target.BlueprintName,
+					TestConfiguration:      configuration.Name,
+					Kind:                   testTargetKindUnit,
+					IsUITestBundle:         target.IsUITestBundle,
+					OnlyTestIdentifiers:    target.OnlyTestIdentifiers,
+					SkipTestIdentifiers:    target.SkipTestIdentifiers,
+					ParallelizationEnabled: target.ParallelizationEnabled,
+				}
+
+				hostAppPth := target.TestHostPath
+				if target.IsUITestBundle {
+					info.Kind = testTargetKindUI
+					hostAppPth = target.UITargetAppPath
+				}
+				if isInTestBundle(hostAppPth) {
+					info.HostApp = relativeToSYMRoot(symRoot, target.ResolvePath(hostAppPth, symRoot))
+				}
+
+				if testable, ok := testables[target.BlueprintName]; ok {
+					info.InScheme = true
+					info.SkippedInScheme = testable.Skipped == "YES"
+					info.ParallelizableInScheme = testable.Parallelizable == "YES"
+				}
+
+				xctestrunTargets.TestTargets = append(xctestrunTargets.TestTargets, info)
+			}
+		}
+
+		report.Xctestruns = append(report.Xctestruns, xctestrunTargets)
+	}
+	sort.Slice(report.Xctestruns, func(i, j int) bool {
+		return report.Xctestruns[i].Path < report.Xctestruns[j].Path
+	})
+
+	return report, nil
+}
+
+// printTestTargetsReport prints the test targets of every xctestrun file as a table.
+func (b XcodebuildBuilder) printTestTargetsReport(report testTargetsReport) {
+	b.logger.Println()
+	b.logger.Infof("Test targets")
+
+	for _, xctestrun := range report.Xctestruns {
+		b.logger.Printf("%s:", xctestrun.Path)
+
+		var table strings.Builder
+		w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TARGET\tKIND\tHOST APP\tONLY TESTING\tSKIP TESTING\tPARALLEL")
+		for _, target := range xctestrun.TestTargets {
+			name := target.Name
+			if target.TestConfiguration != "" {
+				name = fmt.Sprintf("%s (%s)", target.Name, target.TestConfiguration)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				name,
+				target.Kind,
+				valueOrDash(filepath.Base(target.HostApp), target.HostApp != ""),
+				valueOrDash(strings.Join(target.OnlyTestIdentifiers, ", "), len(target.OnlyTestIdentifiers) > 0),
+				valueOrDash(strings.Join(target.SkipTestIdentifiers, ", "), len(target.SkipTestIdentifiers) > 0),
+				yesNo(target.ParallelizationEnabled),
+			)
+		}
+		if err := w.Flush(); err != nil {
+			b.logger.Warnf("Failed to print the test targets of %s: %s", xctestrun.Path, err)
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n") {
+			b.logger.Printf("  %s", line)
+		}
+	}
+}
+
+func (b XcodebuildBuilder) exportTestTargetsReport(outputDir string, report testTargetsReport) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode test targets: %w", err)
+	}
+
+	reportPth := filepath.Join(outputDir, testTargetsBaseName)
+	if err := output.ExportOutputFileContent(string(content), reportPth, testTargetsPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", testTargetsPathEnvKey, err)
+	}
+	b.logger.Donef("The test targets are available in %s env: %s", testTargetsPathEnvKey, reportPth)
+
+	return nil
+}
+
+func valueOrDash(value string, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return value
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
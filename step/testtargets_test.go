@@ -0,0 +1,103 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcscheme"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenXctestrunAndSchemeTestables_WhenCreateTestTargetsReport_ThenListsTestTargets(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	xctestrunPth := "/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	data, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(data, nil)
+
+	scheme := &xcscheme.Scheme{
+		TestAction: xcscheme.TestAction{
+			Testables: []xcscheme.TestableReference{
+				{Skipped: "NO", Parallelizable: "YES", BuildableReference: xcscheme.BuildableReference{BlueprintName: "BullsEyeTests"}},
+				{Skipped: "YES", BuildableReference: xcscheme.BuildableReference{BlueprintName: "BullsEyeSlowTests"}},
+			},
+		},
+	}
+
+	// When
+	report, err := step.createTestTargetsReport("/symroot", []string{xctestrunPth}, scheme)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, testTargetsReport{
+		Xctestruns: []xctestrunTestTargets{
+			{
+				Path: "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun",
+				TestTargets: []testTargetInfo{
+					{
+						Name:                   "BullsEyeTests",
+						TestConfiguration:      "Test Scheme Action",
+						Kind:                   testTargetKindUnit,
+						HostApp:                "Debug-iphonesimulator/BullsEye.app",
+						ParallelizationEnabled: true,
+						InScheme:               true,
+						ParallelizableInScheme: true,
+					},
+					{
+						Name:              "BullsEyeUITests",
+						TestConfiguration: "Test Scheme Action",
+						Kind:              testTargetKindUI,
+						HostApp:           "Debug-iphonesimulator/BullsEye.app",
+						IsUITestBundle:    true,
+					},
+				},
+			},
+		},
+	}, report)
+}
+
+func Test_GivenLogicTestTarget_WhenCreateTestTargetsReport_ThenHasNoHostApp(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	xctestrunPth := "/symroot/BullsEye.xctestrun"
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>BullsEyeLogicTests</key>
+	<dict>
+		<key>BlueprintName</key>
+		<string>BullsEyeLogicTests</string>
+		<key>OnlyTestIdentifiers</key>
+		<array>
+			<string>BullsEyeLogicTests/testScore</string>
+		</array>
+		<key>TestBundlePath</key>
+		<string>__TESTROOT__/Debug-iphonesimulator/BullsEyeLogicTests.xctest</string>
+		<key>TestHostPath</key>
+		<string>__PLATFORMS__/iPhoneSimulator.platform/Developer/Library/Xcode/Agents/xctest</string>
+	</dict>
+	<key>__xctestrun_metadata__</key>
+	<dict>
+		<key>FormatVersion</key>
+		<integer>1</integer>
+	</dict>
+</dict>
+</plist>`), nil)
+
+	// When
+	report, err := step.createTestTargetsReport("/symroot", []string{xctestrunPth}, nil)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []testTargetInfo{
+		{
+			Name:                "BullsEyeLogicTests",
+			Kind:                testTargetKindUnit,
+			OnlyTestIdentifiers: []string{"BullsEyeLogicTests/testScore"},
+		},
+	}, report.Xctestruns[0].TestTargets)
+}
@@ -0,0 +1,135 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+)
+
+// timeoutCommandWaitDelay is how long the output of a killed command is read at most:
+// git processes started by xcodebuild may keep the output open after xcodebuild is killed.
+const timeoutCommandWaitDelay = 10 * time.Second
+
+type timeoutCommandFactory struct {
+	envRepository env.Repository
+	timeout       time.Duration
+}
+
+// NewTimeoutCommandFactory creates a command factory, which kills the commands running longer than the timeout
+// (0 means no limit). A killed command fails with an error wrapping context.DeadlineExceeded.
+// The ErrorFinder of the command options is not used.
+func NewTimeoutCommandFactory(envRepository env.Repository, timeout time.Duration) command.Factory {
+	return timeoutCommandFactory{
+		envRepository: envRepository,
+		timeout:       timeout,
+	}
+}
+
+func (f timeoutCommandFactory) Create(name string, args []string, opts *command.Opts) command.Command {
+	c := &timeoutCommand{
+		name:    name,
+		args:    args,
+		env:     f.envRepository.List(),
+		timeout: f.timeout,
+	}
+	if opts != nil {
+		c.opts = *opts
+		c.env = append(c.env, opts.Env...)
+	}
+	return c
+}
+
+type timeoutCommand struct {
+	name    string
+	args    []string
+	opts    command.Opts
+	env     []string
+	timeout time.Duration
+
+	cmd    *exec.Cmd
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (c *timeoutCommand) PrintableCommandArgs() string {
+	printableArgs := []string{c.name}
+	for _, arg := range c.args {
+		printableArgs = append(printableArgs, fmt.Sprintf("\"%s\"", arg))
+	}
+	return strings.Join(printableArgs, " ")
+}
+
+func (c *timeoutCommand) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+func (c *timeoutCommand) RunAndReturnExitCode() (int, error) {
+	err := c.Run()
+	if c.cmd.ProcessState == nil {
+		return -1, err
+	}
+	return c.cmd.ProcessState.ExitCode(), err
+}
+
+func (c *timeoutCommand) RunAndReturnTrimmedOutput() (string, error) {
+	var out strings.Builder
+	c.opts.Stdout = &out
+	err := c.Run()
+	return strings.TrimSpace(out.String()), err
+}
+
+func (c *timeoutCommand) RunAndReturnTrimmedCombinedOutput() (string, error) {
+	var out strings.Builder
+	c.opts.Stdout = &out
+	c.opts.Stderr = &out
+	err := c.Run()
+	return strings.TrimSpace(out.String()), err
+}
+
+func (c *timeoutCommand) Start() error {
+	c.ctx, c.cancel = context.Background(), func() {}
+	if c.timeout > 0 {
+		c.ctx, c.cancel = context.WithTimeout(c.ctx, c.timeout)
+	}
+
+	c.cmd = exec.CommandContext(c.ctx, c.name, c.args...)
+	c.cmd.Stdout = c.opts.Stdout
+	c.cmd.Stderr = c.opts.Stderr
+	c.cmd.Stdin = c.opts.Stdin
+	c.cmd.Env = c.env
+	c.cmd.Dir = c.opts.Dir
+	c.cmd.WaitDelay = timeoutCommandWaitDelay
+
+	if err := c.cmd.Start(); err != nil {
+		c.cancel()
+		return fmt.Errorf("executing command failed (%s): %w", c.PrintableCommandArgs(), err)
+	}
+	return nil
+}
+
+func (c *timeoutCommand) Wait() error {
+	defer c.cancel()
+
+	err := c.cmd.Wait()
+	if c.ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("command timed out after %s (%s): %w", c.timeout, c.PrintableCommandArgs(), c.ctx.Err())
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return command.NewExitStatusError(c.PrintableCommandArgs(), exitErr, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("executing command failed (%s): %w", c.PrintableCommandArgs(), err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package step
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenCommandRunningLongerThanTimeout_WhenRun_ThenCommandIsKilled(t *testing.T) {
+	// Given
+	factory := NewTimeoutCommandFactory(env.NewRepository(), 100*time.Millisecond)
+	cmd := factory.Create("sleep", []string{"10"}, nil)
+
+	// When
+	startTime := time.Now()
+	err := cmd.Run()
+
+	// Then
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(startTime), 5*time.Second)
+}
+
+func Test_GivenCommandFinishingInTime_WhenRunAndReturnTrimmedOutput_ThenOutputIsReturned(t *testing.T) {
+	// Given
+	factory := NewTimeoutCommandFactory(env.NewRepository(), 0)
+	cmd := factory.Create("echo", []string{"Resolved source packages:"}, nil)
+
+	// When
+	output, err := cmd.RunAndReturnTrimmedOutput()
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, "Resolved source packages:", output)
+}
@@ -14,6 +14,7 @@ type FileManager interface {
 	ReadFile(pth string) ([]byte, error)
 	WriteFile(filename string, data []byte, perm fs.FileMode) error
 	ReadDir(name string) ([]os.DirEntry, error)
+	Remove(name string) error
 }
 
 type fileManager struct {
@@ -35,6 +36,10 @@ func (m fileManager) ReadDir(name string) ([]os.DirEntry, error) {
 	return os.ReadDir(name)
 }
 
+func (m fileManager) Remove(name string) error {
+	return os.Remove(name)
+}
+
 func printLastLinesOfXcodebuildTestLog(rawXcodebuildOutput string, isRunSuccess bool, logger log.Logger) {
 	const lastLines = "\nLast lines of the build log:"
 	if !isRunSuccess {
@@ -72,3 +77,25 @@ func findBuildSetting(options []string, key string) string {
 
 	return ""
 }
+
+// findOptionValue returns the value of an xcodebuild option which is followed by its value (-resultBundlePath <path>).
+func findOptionValue(options []string, option string) string {
+	for i, o := range options {
+		if o == option && i+1 < len(options) {
+			return options[i+1]
+		}
+	}
+
+	return ""
+}
+
+// parseList splits a newline or pipe (|) separated input value, dropping the empty items.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == '|' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
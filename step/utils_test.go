@@ -1,6 +1,9 @@
 package step
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func Test_findBuildSetting(t *testing.T) {
 	tests := []struct {
@@ -42,3 +45,75 @@ func Test_findBuildSetting(t *testing.T) {
 		})
 	}
 }
+
+func Test_findOptionValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		option  string
+		want    string
+	}{
+		{
+			name:    "nil test",
+			options: nil,
+			option:  "-resultBundlePath",
+			want:    "",
+		},
+		{
+			name:    "option not found",
+			options: []string{"SYMROOT=tmp", "ARCHS=arm64"},
+			option:  "-resultBundlePath",
+			want:    "",
+		},
+		{
+			name:    "option without value",
+			options: []string{"SYMROOT=tmp", "-resultBundlePath"},
+			option:  "-resultBundlePath",
+			want:    "",
+		},
+		{
+			name:    "option found",
+			options: []string{"SYMROOT=tmp", "-resultBundlePath", "tmp/Test.xcresult", "ARCHS=arm64"},
+			option:  "-resultBundlePath",
+			want:    "tmp/Test.xcresult",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findOptionValue(tt.options, tt.option); got != tt.want {
+				t.Errorf("findOptionValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseList(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  nil,
+		},
+		{
+			name:  "newline separated",
+			value: "*.dSYM\n*.swiftmodule\n",
+			want:  []string{"*.dSYM", "*.swiftmodule"},
+		},
+		{
+			name:  "pipe separated with spaces",
+			value: " *.dSYM | *.swiftdoc ||",
+			want:  []string{"*.dSYM", "*.swiftdoc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseList(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
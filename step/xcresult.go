@@ -0,0 +1,46 @@
+package step
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-steputils/output"
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+)
+
+const (
+	xcresultPathEnvKey    = "BITRISE_XCRESULT_PATH"
+	xcresultZipPathEnvKey = "BITRISE_XCRESULT_ZIP_PATH"
+	xcresultBaseName      = "build-for-testing.xcresult"
+)
+
+// xcresultPath returns the result bundle path passed to xcodebuild's -resultBundlePath option.
+// The location is fixed, so that downstream steps and dashboards can find it without parsing the outputs.
+func xcresultPath(outputDir string) string {
+	return filepath.Join(outputDir, xcresultBaseName)
+}
+
+// exportXcresult zips the result bundle of the build-for-testing run next to it, and exports both paths.
+func (b XcodebuildBuilder) exportXcresult(opts ExportOpts) error {
+	xcresultPth := opts.XcresultPth
+	xcresultZipPth := xcresultPth + ".zip"
+	if err := b.archiver.Archive(filepath.Dir(xcresultPth), []string{filepath.Base(xcresultPth)}, xcresultZipPth, archiver.Opts{
+		Format:           archiver.FormatZip,
+		CompressionLevel: opts.CompressionLevel,
+		Reproducible:     opts.Reproducible,
+	}); err != nil {
+		return fmt.Errorf("failed to zip result bundle: %w", err)
+	}
+
+	if err := output.ExportOutputFile(xcresultPth, xcresultPth, xcresultPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", xcresultPathEnvKey, err)
+	}
+	b.logger.Donef("The result bundle is available in %s env: %s", xcresultPathEnvKey, xcresultPth)
+
+	if err := output.ExportOutputFile(xcresultZipPth, xcresultZipPth, xcresultZipPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", xcresultZipPathEnvKey, err)
+	}
+	b.logger.Donef("The zipped result bundle is available in %s env: %s", xcresultZipPathEnvKey, xcresultZipPth)
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package step
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/archiver"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenResultBundle_WhenExportXcresult_ThenZipsItNextToTheBundle(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.archiver.On("Archive", "/output", []string{"build-for-testing.xcresult"}, "/output/build-for-testing.xcresult.zip", archiver.Opts{
+		Format:           archiver.FormatZip,
+		CompressionLevel: 6,
+	}).Return(errors.New("disk full"))
+
+	opts := ExportOpts{
+		RunOut:           RunOut{XcresultPth: xcresultPath("/output")},
+		OutputDir:        "/output",
+		CompressionLevel: 6,
+	}
+
+	// When
+	err := step.exportXcresult(opts)
+
+	// Then
+	require.EqualError(t, err, "failed to zip result bundle: disk full")
+	stepMocks.archiver.AssertExpectations(t)
+}
@@ -0,0 +1,130 @@
+package step
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
+)
+
+// xctestrunCustomization is the test run configuration added to the test targets of the xctestrun files.
+type xctestrunCustomization struct {
+	EnvironmentVariables        map[string]string
+	TestingEnvironmentVariables map[string]string
+	CommandLineArguments        []string
+	// TestTargets are the names of the customized test targets, every test target is customized if empty.
+	TestTargets []string
+}
+
+func (c xctestrunCustomization) isEmpty() bool {
+	return len(c.EnvironmentVariables) == 0 && len(c.TestingEnvironmentVariables) == 0 && len(c.CommandLineArguments) == 0
+}
+
+func (c xctestrunCustomization) isCustomized(target *xctestrun.TestTarget) bool {
+	if len(c.TestTargets) == 0 {
+		return true
+	}
+	for _, name := range c.TestTargets {
+		if name == target.BlueprintName {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the environment variables (overriding the existing values of the same keys) and appends the command line arguments.
+func (c xctestrunCustomization) apply(target *xctestrun.TestTarget) {
+	target.EnvironmentVariables = mergeEnvironmentVariables(target.EnvironmentVariables, c.EnvironmentVariables)
+	target.TestingEnvironmentVariables = mergeEnvironmentVariables(target.TestingEnvironmentVariables, c.TestingEnvironmentVariables)
+	target.CommandLineArguments = append(target.CommandLineArguments, c.CommandLineArguments...)
+}
+
+func mergeEnvironmentVariables(envs, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return envs
+	}
+	if envs == nil {
+		envs = map[string]string{}
+	}
+	for key, value := range overrides {
+		envs[key] = value
+	}
+	return envs
+}
+
+// customizeXctestruns applies the customization to the test targets of every xctestrun file.
+func (b XcodebuildBuilder) customizeXctestruns(xctestrunPths []string, customization xctestrunCustomization) error {
+	if customization.isEmpty() {
+		return nil
+	}
+
+	b.logger.Println()
+	b.logger.Infof("Customizing xctestrun files")
+
+	found := map[string]bool{}
+	for _, xctestrunPth := range xctestrunPths {
+		testRun, err := b.readXctestrun(xctestrunPth)
+		if err != nil {
+			b.logger.Warnf("Failed to read %s, it is not customized: %s", xctestrunPth, err)
+			continue
+		}
+
+		var customized []string
+		for _, target := range testRun.TestTargets() {
+			if !customization.isCustomized(target) {
+				continue
+			}
+			customization.apply(target)
+			found[target.BlueprintName] = true
+			customized = append(customized, target.BlueprintName)
+		}
+		if len(customized) == 0 {
+			continue
+		}
+
+		if err := b.writeXctestrun(xctestrunPth, testRun); err != nil {
+			return err
+		}
+		b.logger.Printf("%s: %s", xctestrunPth, strings.Join(customized, ", "))
+	}
+
+	var missing []string
+	for _, name := range customization.TestTargets {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		b.logger.Warnf("Test target(s) to customize not found in the xctestrun files: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// parseEnvironmentVariables parses the newline separated KEY=value items of an input value, dropping the empty lines.
+// Values can contain = and | characters.
+func parseEnvironmentVariables(value string) (map[string]string, error) {
+	envs := map[string]string{}
+	for _, line := range parseLines(value) {
+		key, envValue, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid environment variable (%s), use the KEY=value format", line)
+		}
+		envs[key] = envValue
+	}
+	return envs, nil
+}
+
+// parseLines splits a newline separated input value, dropping the empty lines.
+func parseLines(value string) []string {
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,112 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xcode-build-for-test/xctestrun"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseEnvironmentVariables(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[string]string{},
+		},
+		{
+			name:  "values with separators",
+			value: "API_BASE_URL=https://staging.example.com?a=b\n\n  FLAGS = new_onboarding|dark_mode\nEMPTY=",
+			want:  map[string]string{"API_BASE_URL": "https://staging.example.com?a=b", "FLAGS": " new_onboarding|dark_mode", "EMPTY": ""},
+		},
+		{
+			name:    "missing separator",
+			value:   "API_BASE_URL",
+			wantErr: true,
+		},
+		{
+			name:    "missing key",
+			value:   "=value",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEnvironmentVariables(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_GivenCustomizedTestTargets_WhenCustomizeXctestruns_ThenOnlyNamedTargetsAreCustomized(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Println").Return()
+	stepMocks.logger.On("Infof", mock.Anything).Return()
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+
+	content, err := os.ReadFile(filepath.Join("..", "xctestrun", "testdata", "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"))
+	require.NoError(t, err)
+	xctestrunPth := "/test_bundle/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	stepMocks.fileManager.On("ReadFile", xctestrunPth).Return(content, nil)
+
+	var written []byte
+	stepMocks.fileManager.On("WriteFile", xctestrunPth, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]byte)
+	})
+
+	customization := xctestrunCustomization{
+		EnvironmentVariables:        map[string]string{"API_BASE_URL": "https://staging.example.com"},
+		TestingEnvironmentVariables: map[string]string{"FEATURE_FLAGS": "new_onboarding|dark_mode"},
+		CommandLineArguments:        []string{"-UITestMode"},
+		TestTargets:                 []string{"BullsEyeUITests", "BullsEyeSnapshotTests"},
+	}
+
+	// When
+	err = step.customizeXctestruns([]string{xctestrunPth}, customization)
+
+	// Then
+	require.NoError(t, err)
+	stepMocks.logger.AssertNumberOfCalls(t, "Warnf", 1)
+
+	testRun, err := xctestrun.Parse(written)
+	require.NoError(t, err)
+	for _, target := range testRun.TestTargets() {
+		switch target.BlueprintName {
+		case "BullsEyeUITests":
+			require.Equal(t, "https://staging.example.com", target.EnvironmentVariables["API_BASE_URL"])
+			require.Equal(t, "new_onboarding|dark_mode", target.TestingEnvironmentVariables["FEATURE_FLAGS"])
+			require.Equal(t, "-UITestMode", target.CommandLineArguments[len(target.CommandLineArguments)-1])
+		default:
+			require.NotContains(t, target.EnvironmentVariables, "API_BASE_URL")
+			require.NotContains(t, target.TestingEnvironmentVariables, "FEATURE_FLAGS")
+			require.NotContains(t, target.CommandLineArguments, "-UITestMode")
+		}
+	}
+}
+
+func Test_GivenNoCustomization_WhenCustomizeXctestruns_ThenXctestrunsAreNotRead(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+
+	// When
+	err := step.customizeXctestruns([]string{"/test_bundle/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"}, xctestrunCustomization{TestTargets: []string{"BullsEyeUITests"}})
+
+	// Then
+	require.NoError(t, err)
+	stepMocks.fileManager.AssertNotCalled(t, "ReadFile", mock.Anything)
+}
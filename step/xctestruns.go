@@ -0,0 +1,191 @@
+package step
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/output"
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcscheme"
+)
+
+// xctestrunsByTestPlan maps test plan names to destinations to xctestrun file paths.
+type xctestrunsByTestPlan map[string]map[string]string
+
+// xctestrunDestination returns the destination part of the xctestrun file name.
+// xctestrun file name layout: <scheme>_<test_plan>_<destination>.xctestrun or <scheme>_<destination>.xctestrun
+func xctestrunDestination(xctestrunPth string) string {
+	name := strings.TrimSuffix(filepath.Base(xctestrunPth), xctestrunExt)
+	if idx := strings.LastIndex(name, "_"); idx != -1 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// xctestrunTestPlanFromFileName returns the test plan part of the xctestrun file name,
+// or the scheme name if the file was generated without a test plan.
+func xctestrunTestPlanFromFileName(xctestrunPth, scheme string) string {
+	name := strings.TrimSuffix(filepath.Base(xctestrunPth), xctestrunExt)
+	name = strings.TrimSuffix(name, "_"+xctestrunDestination(xctestrunPth))
+	if testPlan := strings.TrimPrefix(name, scheme+"_"); testPlan != name && testPlan != "" {
+		return testPlan
+	}
+	return scheme
+}
+
+// mapXctestrunsByTestPlan groups the xctestrun files by the test plan (and destination) they were generated for.
+// The test plan name is read from the xctestrun file, files without a test plan (or files which can not be parsed)
+// fall back to the test plan part of their file name.
+func (b XcodebuildBuilder) mapXctestrunsByTestPlan(xctestrunPths []string, scheme string) xctestrunsByTestPlan {
+	mapping := xctestrunsByTestPlan{}
+	for _, xctestrunPth := range xctestrunPths {
+		testPlan := xctestrunTestPlanFromFileName(xctestrunPth, scheme)
+
+		testRun, err := b.readXctestrun(xctestrunPth)
+		if err != nil {
+			b.logger.Warnf("Failed to read %s, mapping it to the %s test plan based on its file name: %s", filepath.Base(xctestrunPth), testPlan, err)
+		} else if testRun.TestPlan != nil && testRun.TestPlan.Name != "" {
+			testPlan = testRun.TestPlan.Name
+		}
+
+		if mapping[testPlan] == nil {
+			mapping[testPlan] = map[string]string{}
+		}
+		mapping[testPlan][xctestrunDestination(xctestrunPth)] = xctestrunPth
+	}
+	return mapping
+}
+
+// validateTestPlans checks that every requested test plan is associated with the scheme.
+func validateTestPlans(scheme *xcscheme.Scheme, testPlans []string) error {
+	var available []string
+	if scheme.TestAction.TestPlans != nil {
+		for _, testPlan := range scheme.TestAction.TestPlans.TestPlanReferences {
+			available = append(available, testPlan.Name())
+		}
+	}
+
+	var missing []string
+	for _, testPlan := range testPlans {
+		if !sliceutil.IsStringInSlice(testPlan, available) {
+			missing = append(missing, testPlan)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("test plan(s) not found in %s scheme: %s (available test plans: %s)", scheme.Name, strings.Join(missing, ", "), strings.Join(available, ", "))
+	}
+	return nil
+}
+
+// filterXctestrunsByTestPlan keeps the xctestrun files of the given test plans.
+func filterXctestrunsByTestPlan(xctestrunPths []string, byTestPlan xctestrunsByTestPlan, testPlans []string) ([]string, xctestrunsByTestPlan) {
+	filteredByTestPlan := xctestrunsByTestPlan{}
+	kept := map[string]bool{}
+	for _, testPlan := range testPlans {
+		if xctestrunPthByDestination, ok := byTestPlan[testPlan]; ok {
+			filteredByTestPlan[testPlan] = xctestrunPthByDestination
+			for _, xctestrunPth := range xctestrunPthByDestination {
+				kept[xctestrunPth] = true
+			}
+		}
+	}
+
+	var filtered []string
+	for _, xctestrunPth := range xctestrunPths {
+		if kept[xctestrunPth] {
+			filtered = append(filtered, xctestrunPth)
+		}
+	}
+	return filtered, filteredByTestPlan
+}
+
+// removeFilteredXctestruns removes the xctestrun files of the not requested test plans from the build root,
+// so that the exported test bundle directory only contains the requested test plans' xctestrun files.
+func (b XcodebuildBuilder) removeFilteredXctestruns(generatedXctestrunPths, keptXctestrunPths []string) {
+	for _, xctestrunPth := range generatedXctestrunPths {
+		if sliceutil.IsStringInSlice(xctestrunPth, keptXctestrunPths) {
+			continue
+		}
+
+		b.logger.Printf("Removing %s, its test plan was not requested", filepath.Base(xctestrunPth))
+		b.removeXctestrun(xctestrunPth)
+	}
+}
+
+// removeXctestrun removes an xctestrun file dropped from the test bundle from the build root,
+// a failed removal is only reported as a warning.
+func (b XcodebuildBuilder) removeXctestrun(xctestrunPth string) {
+	if err := b.fileManager.Remove(xctestrunPth); err != nil && !os.IsNotExist(err) {
+		b.logger.Warnf("Failed to remove %s: %s", xctestrunPth, err)
+	}
+}
+
+// dropXctestruns removes the given xctestrun files from the test bundle,
+// if the default xctestrun file is dropped, the first remaining xctestrun file becomes the default.
+func dropXctestruns(bundle testBundle, dropped map[string]bool) testBundle {
+	var xctestrunPths []string
+	for _, xctestrunPth := range bundle.XctestrunPths {
+		if !dropped[xctestrunPth] {
+			xctestrunPths = append(xctestrunPths, xctestrunPth)
+		}
+	}
+	bundle.XctestrunPths = xctestrunPths
+
+	byTestPlan := xctestrunsByTestPlan{}
+	for testPlan, xctestrunPthByDestination := range bundle.XctestrunPthsByTestPlan {
+		for destination, xctestrunPth := range xctestrunPthByDestination {
+			if dropped[xctestrunPth] {
+				continue
+			}
+			if byTestPlan[testPlan] == nil {
+				byTestPlan[testPlan] = map[string]string{}
+			}
+			byTestPlan[testPlan][destination] = xctestrunPth
+		}
+	}
+	bundle.XctestrunPthsByTestPlan = byTestPlan
+
+	if dropped[bundle.DefaultXctestrunPth] && len(xctestrunPths) > 0 {
+		bundle.DefaultXctestrunPth = xctestrunPths[0]
+	}
+
+	return bundle
+}
+
+// testPlanXctestrun returns the first xctestrun file (in the order of xctestrunPths) generated for the test plan.
+func testPlanXctestrun(xctestrunPths []string, byTestPlan xctestrunsByTestPlan, testPlan string) string {
+	xctestrunPthByDestination := byTestPlan[testPlan]
+	for _, xctestrunPth := range xctestrunPths {
+		if xctestrunPthByDestination[xctestrunDestination(xctestrunPth)] == xctestrunPth {
+			return xctestrunPth
+		}
+	}
+	return ""
+}
+
+func (b XcodebuildBuilder) exportXctestruns(outputDir string, xctestrunPths []string, byTestPlan xctestrunsByTestPlan) error {
+	// BITRISE_XCTESTRUN_FILE_PATH_LIST
+	xctestrunPthList := strings.Join(xctestrunPths, "|")
+	if err := tools.ExportEnvironmentWithEnvman(xctestrunPathListEnvKey, xctestrunPthList); err != nil {
+		return err
+	}
+	b.logger.Donef("The built xctestrun files are available in %s env: %s", xctestrunPathListEnvKey, xctestrunPthList)
+
+	// BITRISE_XCTESTRUN_MANIFEST_PATH
+	manifest, err := json.MarshalIndent(byTestPlan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode xctestrun manifest: %w", err)
+	}
+
+	manifestPth := filepath.Join(outputDir, xctestrunManifestBaseName)
+	if err := output.ExportOutputFileContent(string(manifest), manifestPth, xctestrunManifestPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", xctestrunManifestPathEnvKey, err)
+	}
+	b.logger.Donef("The xctestrun manifest (test plan -> destination -> xctestrun) is available in %s env: %s", xctestrunManifestPathEnvKey, manifestPth)
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package step
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcscheme"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_xctestrunTestPlanFromFileName(t *testing.T) {
+	tests := []struct {
+		name         string
+		xctestrunPth string
+		scheme       string
+		wantTestPlan string
+		wantDest     string
+	}{
+		{
+			name:         "test plan",
+			xctestrunPth: "/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun",
+			scheme:       "BullsEye",
+			wantTestPlan: "UnitTests",
+			wantDest:     "iphonesimulator15.5-arm64",
+		},
+		{
+			name:         "test plan with underscore",
+			xctestrunPth: "/symroot/BullsEye_UI_Tests_iphoneos16.0-arm64.xctestrun",
+			scheme:       "BullsEye",
+			wantTestPlan: "UI_Tests",
+			wantDest:     "iphoneos16.0-arm64",
+		},
+		{
+			name:         "scheme with underscore",
+			xctestrunPth: "/symroot/Bulls_Eye_UnitTests_iphoneos16.0-arm64.xctestrun",
+			scheme:       "Bulls_Eye",
+			wantTestPlan: "UnitTests",
+			wantDest:     "iphoneos16.0-arm64",
+		},
+		{
+			name:         "no test plan",
+			xctestrunPth: "/symroot/BullsEye_iphonesimulator15.5-arm64.xctestrun",
+			scheme:       "BullsEye",
+			wantTestPlan: "BullsEye",
+			wantDest:     "iphonesimulator15.5-arm64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := xctestrunTestPlanFromFileName(tt.xctestrunPth, tt.scheme); got != tt.wantTestPlan {
+				t.Errorf("xctestrunTestPlanFromFileName() = %v, want %v", got, tt.wantTestPlan)
+			}
+			if got := xctestrunDestination(tt.xctestrunPth); got != tt.wantDest {
+				t.Errorf("xctestrunDestination() = %v, want %v", got, tt.wantDest)
+			}
+		})
+	}
+}
+
+func Test_validateTestPlans(t *testing.T) {
+	scheme := &xcscheme.Scheme{
+		Name: "BullsEye",
+		TestAction: xcscheme.TestAction{
+			TestPlans: &xcscheme.TestPlans{
+				TestPlanReferences: []xcscheme.TestPlanReference{
+					{Reference: "container:UnitTests.xctestplan"},
+					{Reference: "container:FullTests.xctestplan", Default: "YES"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		scheme    *xcscheme.Scheme
+		testPlans []string
+		wantErr   bool
+	}{
+		{name: "existing test plans", scheme: scheme, testPlans: []string{"FullTests", "UnitTests"}},
+		{name: "missing test plan", scheme: scheme, testPlans: []string{"FullTests", "Unit"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTestPlans(tt.scheme, tt.testPlans)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_GivenSchemeWithoutTestPlans_WhenRunWithTestPlan_ThenTestPlanIsNotValidated(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	mockLoggerCalls(stepMocks.logger)
+
+	stepMocks.xcodeproject.On("Scheme", "/BullsEye/BullsEye.xcodeproj", "BullsEye").Return(&xcscheme.Scheme{Name: "BullsEye"}, nil)
+	stepMocks.packageResolutionRunner.On("Run", "", mock.Anything, []string(nil)).Return(xcodecommand.Output{ExitCode: 1}, errors.New("exit status 1"))
+
+	cfg := Config{
+		ProjectPath:       "/BullsEye/BullsEye.xcodeproj",
+		Scheme:            "BullsEye",
+		TestPlans:         []string{"FullTests"},
+		ResolvePackages:   true,
+		PackageResolution: packageResolution{RetryPolicy: retryPolicy{MaxAttempts: 1}},
+	}
+
+	// When
+	_, err := step.Run(cfg)
+
+	// Then
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to resolve Swift package dependencies")
+	stepMocks.logger.AssertCalled(t, "Warnf", mock.Anything, mock.Anything)
+}
+
+func Test_filterXctestrunsByTestPlan(t *testing.T) {
+	unitSimulatorPth := "/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun"
+	unitDevicePth := "/symroot/BullsEye_UnitTests_iphoneos15.5-arm64.xctestrun"
+	fullSimulatorPth := "/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	byTestPlan := xctestrunsByTestPlan{
+		"UnitTests": {"iphonesimulator15.5-arm64": unitSimulatorPth, "iphoneos15.5-arm64": unitDevicePth},
+		"FullTests": {"iphonesimulator15.5-arm64": fullSimulatorPth},
+	}
+
+	filtered, filteredByTestPlan := filterXctestrunsByTestPlan([]string{unitSimulatorPth, fullSimulatorPth, unitDevicePth}, byTestPlan, []string{"UnitTests", "UITests"})
+
+	require.Equal(t, []string{unitSimulatorPth, unitDevicePth}, filtered)
+	require.Equal(t, xctestrunsByTestPlan{"UnitTests": byTestPlan["UnitTests"]}, filteredByTestPlan)
+	require.Equal(t, unitSimulatorPth, testPlanXctestrun(filtered, filteredByTestPlan, "UnitTests"))
+	require.Equal(t, "", testPlanXctestrun(filtered, filteredByTestPlan, "FullTests"))
+}
+
+func Test_GivenNotRequestedXctestruns_WhenRemoveFilteredXctestruns_ThenRemovesThemWithTheFileManager(t *testing.T) {
+	// Given
+	step, stepMocks := createStepAndMocks()
+	stepMocks.logger.On("Printf", mock.Anything, mock.Anything).Return()
+	stepMocks.logger.On("Warnf", mock.Anything, mock.Anything).Return()
+
+	unitTestsPth := "/symroot/BullsEye_UnitTests_iphonesimulator15.5-arm64.xctestrun"
+	fullTestsPth := "/symroot/BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+	uiTestsPth := "/symroot/BullsEye_UITests_iphonesimulator15.5-arm64.xctestrun"
+	removedPth := "/symroot/BullsEye_Removed_iphonesimulator15.5-arm64.xctestrun"
+	stepMocks.fileManager.On("Remove", fullTestsPth).Return(nil)
+	stepMocks.fileManager.On("Remove", uiTestsPth).Return(errors.New("permission denied"))
+	stepMocks.fileManager.On("Remove", removedPth).Return(os.ErrNotExist)
+
+	// When
+	step.removeFilteredXctestruns([]string{unitTestsPth, fullTestsPth, uiTestsPth, removedPth}, []string{unitTestsPth})
+
+	// Then
+	stepMocks.fileManager.AssertExpectations(t)
+	stepMocks.fileManager.AssertNotCalled(t, "Remove", unitTestsPth)
+	stepMocks.logger.AssertNumberOfCalls(t, "Warnf", 1)
+}
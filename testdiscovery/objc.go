@@ -0,0 +1,15 @@
+package testdiscovery
+
+import "regexp"
+
+// objcTestMethodPattern matches the instance methods without arguments, optionally defined in a category:
+// -[BullsEyeTests testScoring] or -[BullsEyeTests(Scoring) testScoring]
+var objcTestMethodPattern = regexp.MustCompile(`^-\[([A-Za-z_][A-Za-z0-9_]*)(?:\([A-Za-z0-9_]*\))? (test[A-Za-z0-9_]*)\]$`)
+
+func parseObjCTestSymbol(symbol string) (string, string, bool) {
+	match := objcTestMethodPattern.FindStringSubmatch(symbol)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
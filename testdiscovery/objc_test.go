@@ -0,0 +1,52 @@
+package testdiscovery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseObjCTestSymbol(t *testing.T) {
+	tests := []struct {
+		name       string
+		symbol     string
+		wantClass  string
+		wantMethod string
+		wantOK     bool
+	}{
+		{
+			name:       "instance method",
+			symbol:     "-[BullsEyeObjCTests testLegacyScoring]",
+			wantClass:  "BullsEyeObjCTests",
+			wantMethod: "testLegacyScoring",
+			wantOK:     true,
+		},
+		{
+			name:       "category method",
+			symbol:     "-[BullsEyeObjCTests(Scoring) testBonus]",
+			wantClass:  "BullsEyeObjCTests",
+			wantMethod: "testBonus",
+			wantOK:     true,
+		},
+		{
+			name:   "method with arguments",
+			symbol: "-[BullsEyeObjCTests testWithValue:]",
+		},
+		{
+			name:   "class method",
+			symbol: "+[BullsEyeObjCTests testClassMethod]",
+		},
+		{
+			name:   "not a test method",
+			symbol: "-[BullsEyeObjCTests setUp]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, method, ok := parseObjCTestSymbol(tt.symbol)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.wantClass, class)
+			require.Equal(t, tt.wantMethod, method)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package testdiscovery
+
+import (
+	"strconv"
+	"strings"
+)
+
+// swiftSymbolPrefixes are the mangling prefixes of Swift 5 (and Swift 4.2) symbols, Mach-O symbols have an extra leading underscore.
+var swiftSymbolPrefixes = []string{"_$s", "$s", "_$S", "$S"}
+
+// maxSwiftWords is the number of words the Swift mangling keeps for word substitutions.
+const maxSwiftWords = 26
+
+// parseSwiftTestSymbol demangles the symbols of class methods without parameters and return value:
+//
+//	$s <module> <class> C <method> yy [Ya] [K] F ...
+//
+// For example $s13BullsEyeTestsAAC11testScoringyyF is BullsEyeTests.BullsEyeTests.testScoring() and
+// $s13BullsEyeTests05ScoreC0C14testPerfectHityyKF is BullsEyeTests.ScoreTests.testPerfectHit() throws.
+// Symbols with a suffix (for example the @objc thunk: ...yyFTo) describe the same method.
+// Only the identifier and substitution forms test methods use are supported, other symbols are skipped.
+func parseSwiftTestSymbol(symbol string) (string, string, bool) {
+	var mangled string
+	for _, prefix := range swiftSymbolPrefixes {
+		if strings.HasPrefix(symbol, prefix) {
+			mangled = strings.TrimPrefix(symbol, prefix)
+			break
+		}
+	}
+	if mangled == "" {
+		return "", "", false
+	}
+
+	d := swiftDemangler{text: mangled}
+	if _, ok := d.identifier(); !ok {
+		return "", "", false
+	}
+	class, ok := d.identifier()
+	if !ok || !d.nextIf("C") {
+		return "", "", false
+	}
+	method, ok := d.identifier()
+	if !ok || !strings.HasPrefix(method, "test") {
+		return "", "", false
+	}
+
+	// function type without parameters and return value, optionally async and throwing
+	if !d.nextIf("yy") {
+		return "", "", false
+	}
+	d.nextIf("Ya")
+	d.nextIf("K")
+	if !d.nextIf("F") {
+		return "", "", false
+	}
+
+	return class, method, true
+}
+
+// swiftDemangler follows the identifier demangling of the Swift runtime (swift/lib/Demangling/Demangler.cpp).
+type swiftDemangler struct {
+	text string
+	pos  int
+	// words are the words of the already demangled identifiers, referenced by the word substitutions.
+	words []string
+	// substitutions are the already demangled identifiers, referenced by A<index> substitutions.
+	substitutions []string
+}
+
+func (d *swiftDemangler) peek() byte {
+	if d.pos < len(d.text) {
+		return d.text[d.pos]
+	}
+	return 0
+}
+
+func (d *swiftDemangler) nextIf(prefix string) bool {
+	if !strings.HasPrefix(d.text[d.pos:], prefix) {
+		return false
+	}
+	d.pos += len(prefix)
+	return true
+}
+
+func (d *swiftDemangler) natural() (int, bool) {
+	start := d.pos
+	for isDigit(d.peek()) {
+		d.pos++
+	}
+	if start == d.pos {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(d.text[start:d.pos])
+	return n, err == nil
+}
+
+// identifier demangles a literal (<length><identifier>), word substituted (0...) or substituted (A<index>) identifier.
+// Punycoded (00...) identifiers and multi substitutions are not supported.
+func (d *swiftDemangler) identifier() (string, bool) {
+	if d.nextIf("A") {
+		c := d.peek()
+		if !isUpperLetter(c) || int(c-'A') >= len(d.substitutions) {
+			return "", false
+		}
+		d.pos++
+		return d.substitutions[c-'A'], true
+	}
+
+	if !isDigit(d.peek()) {
+		return "", false
+	}
+
+	hasWordSubstitutions := false
+	if d.nextIf("0") {
+		if d.peek() == '0' {
+			return "", false
+		}
+		hasWordSubstitutions = true
+	}
+
+	var identifier strings.Builder
+	for {
+		for hasWordSubstitutions && isLetter(d.peek()) {
+			c := d.peek()
+			d.pos++
+
+			var index int
+			if isLowerLetter(c) {
+				index = int(c - 'a')
+			} else {
+				index = int(c - 'A')
+				hasWordSubstitutions = false
+			}
+			if index >= len(d.words) {
+				return "", false
+			}
+			identifier.WriteString(d.words[index])
+		}
+
+		if d.nextIf("0") {
+			break
+		}
+
+		length, ok := d.natural()
+		if !ok || length <= 0 || d.pos+length > len(d.text) {
+			return "", false
+		}
+		part := d.text[d.pos : d.pos+length]
+		d.pos += length
+
+		identifier.WriteString(part)
+		d.addWords(part)
+
+		if !hasWordSubstitutions {
+			break
+		}
+	}
+
+	d.substitutions = append(d.substitutions, identifier.String())
+	return identifier.String(), true
+}
+
+// addWords collects the words of a literal identifier part, for example Bulls, Eye and Tests of BullsEyeTests.
+// A word starts with a non digit, non underscore character and ends before an underscore or an uppercase letter following a non uppercase letter.
+func (d *swiftDemangler) addWords(part string) {
+	wordStart := -1
+	for i := 0; i <= len(part); i++ {
+		var c byte
+		if i < len(part) {
+			c = part[i]
+		}
+
+		if wordStart >= 0 && isWordEnd(c, part[i-1]) {
+			if i-wordStart >= 2 && len(d.words) < maxSwiftWords {
+				d.words = append(d.words, part[wordStart:i])
+			}
+			wordStart = -1
+		}
+		if wordStart < 0 && isWordStart(c) {
+			wordStart = i
+		}
+	}
+}
+
+func isWordStart(c byte) bool {
+	return !isDigit(c) && c != '_' && c != 0
+}
+
+func isWordEnd(c, prev byte) bool {
+	return c == '_' || c == 0 || (!isUpperLetter(prev) && isUpperLetter(c))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLowerLetter(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isUpperLetter(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+func isLetter(c byte) bool {
+	return isLowerLetter(c) || isUpperLetter(c)
+}
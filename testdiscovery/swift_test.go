@@ -0,0 +1,82 @@
+package testdiscovery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseSwiftTestSymbol(t *testing.T) {
+	tests := []struct {
+		name       string
+		symbol     string
+		wantClass  string
+		wantMethod string
+		wantOK     bool
+	}{
+		{
+			name:       "class named after the module",
+			symbol:     "_$s13BullsEyeTestsAAC11testScoringyyF",
+			wantClass:  "BullsEyeTests",
+			wantMethod: "testScoring",
+			wantOK:     true,
+		},
+		{
+			name:       "word substituted class name",
+			symbol:     "_$s13BullsEyeTests05ScoreC0C14testPerfectHityyKF",
+			wantClass:  "ScoreTests",
+			wantMethod: "testPerfectHit",
+			wantOK:     true,
+		},
+		{
+			name:       "word substitutions only",
+			symbol:     "$s13BullsEyeTests0aB0C8testGameyyF",
+			wantClass:  "BullsEye",
+			wantMethod: "testGame",
+			wantOK:     true,
+		},
+		{
+			name:       "async throwing objc thunk",
+			symbol:     "_$s13BullsEyeTestsAAC19testAsyncHighScoresyyYaKFTo",
+			wantClass:  "BullsEyeTests",
+			wantMethod: "testAsyncHighScores",
+			wantOK:     true,
+		},
+		{
+			name:   "not a test method",
+			symbol: "_$s13BullsEyeTestsAAC5setUpyyF",
+		},
+		{
+			name:   "method with parameters",
+			symbol: "_$s13BullsEyeTestsAAC10testHelperySiF",
+		},
+		{
+			name:   "struct method",
+			symbol: "_$s13BullsEyeTests4GameV8testGameyyF",
+		},
+		{
+			name:   "punycoded identifier",
+			symbol: "_$s13BullsEyeTests00ABCC8testGameyyF",
+		},
+		{
+			name:   "invalid word substitution",
+			symbol: "_$s8BullsEye0zB0C8testGameyyF",
+		},
+		{
+			name:   "truncated symbol",
+			symbol: "_$s13BullsEye",
+		},
+		{
+			name:   "not a Swift symbol",
+			symbol: "_OBJC_CLASS_$_BullsEyeTests",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, method, ok := parseSwiftTestSymbol(tt.symbol)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.wantClass, class)
+			require.Equal(t, tt.wantMethod, method)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package testdiscovery
+
+import (
+	"debug/macho"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Test is a test method compiled into a test bundle.
+type Test struct {
+	Target string
+	Class  string
+	Method string
+}
+
+// Identifier returns the test identifier in the format of xcodebuild's -only-testing option: Target/Class/method.
+func (t Test) Identifier() string {
+	return t.Target + "/" + t.Class + "/" + t.Method
+}
+
+// Discover returns the tests of a test bundle executable based on its symbol table, sorted by their identifiers.
+//
+// Swift tests are the class methods without parameters whose name starts with test,
+// Objective-C tests are the instance methods without arguments whose selector starts with test (-[Class test...]).
+// The tests are found in debug builds, stripped executables have no symbols to enumerate.
+func Discover(target, executablePth string) ([]Test, error) {
+	symbols, err := Symbols(executablePth)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[Test]bool{}
+	for _, symbol := range symbols {
+		class, method, ok := parseObjCTestSymbol(symbol)
+		if !ok {
+			class, method, ok = parseSwiftTestSymbol(symbol)
+		}
+		if !ok {
+			continue
+		}
+
+		found[Test{Target: target, Class: class, Method: method}] = true
+	}
+
+	tests := make([]Test, 0, len(found))
+	for test := range found {
+		tests = append(tests, test)
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Identifier() < tests[j].Identifier()
+	})
+
+	return tests, nil
+}
+
+// Symbols returns the symbol names of a thin or universal (fat) Mach-O binary.
+// Every architecture of a universal binary contains the same tests, so only the first one is read.
+func Symbols(executablePth string) ([]string, error) {
+	fat, err := macho.OpenFat(executablePth)
+	if err == nil {
+		defer fat.Close()
+
+		if len(fat.Arches) == 0 {
+			return nil, fmt.Errorf("%s contains no architecture", executablePth)
+		}
+		return symbolNames(fat.Arches[0].File), nil
+	}
+	if !errors.Is(err, macho.ErrNotFat) {
+		return nil, fmt.Errorf("failed to open %s: %w", executablePth, err)
+	}
+
+	thin, err := macho.Open(executablePth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", executablePth, err)
+	}
+	defer thin.Close()
+
+	return symbolNames(thin), nil
+}
+
+func symbolNames(f *macho.File) []string {
+	if f.Symtab == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(f.Symtab.Syms))
+	for _, symbol := range f.Symtab.Syms {
+		names = append(names, symbol.Name)
+	}
+	return names
+}
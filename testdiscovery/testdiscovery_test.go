@@ -0,0 +1,30 @@
+package testdiscovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscover(t *testing.T) {
+	tests, err := Discover("BullsEyeTests", filepath.Join("testdata", "BullsEyeTests.xctest", "BullsEyeTests"))
+
+	require.NoError(t, err)
+	require.Equal(t, []Test{
+		{Target: "BullsEyeTests", Class: "BullsEyeObjCTests", Method: "testLegacyScoring"},
+		{Target: "BullsEyeTests", Class: "BullsEyeTests", Method: "testAsyncHighScores"},
+		{Target: "BullsEyeTests", Class: "BullsEyeTests", Method: "testScoring"},
+		{Target: "BullsEyeTests", Class: "ScoreTests", Method: "testPerfectHit"},
+	}, tests)
+	require.Equal(t, "BullsEyeTests/ScoreTests/testPerfectHit", tests[3].Identifier())
+}
+
+func TestSymbols_NotMachO(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "BullsEyeTests")
+	require.NoError(t, os.WriteFile(pth, []byte("#!/bin/sh"), 0755))
+
+	_, err := Symbols(pth)
+	require.Error(t, err)
+}
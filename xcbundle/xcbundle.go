@@ -0,0 +1,107 @@
+package xcbundle
+
+import (
+	"debug/macho"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	plist "github.com/bitrise-io/go-plist"
+)
+
+// Bundle describes a built .app, .xctest or .framework bundle.
+type Bundle struct {
+	Path           string
+	Identifier     string
+	ExecutablePath string
+	Architectures  []string
+}
+
+type infoPlist struct {
+	CFBundleIdentifier string `plist:"CFBundleIdentifier"`
+	CFBundleExecutable string `plist:"CFBundleExecutable"`
+}
+
+// Open reads the bundle's Info.plist and the architectures of its executable.
+// Both the iOS (shallow) and the macOS (Contents/...) bundle layouts are supported.
+func Open(pth string) (Bundle, error) {
+	infoPlistPth, executableDir := filepath.Join(pth, "Info.plist"), pth
+	if _, err := os.Stat(infoPlistPth); errors.Is(err, os.ErrNotExist) {
+		infoPlistPth, executableDir = filepath.Join(pth, "Contents", "Info.plist"), filepath.Join(pth, "Contents", "MacOS")
+	}
+
+	data, err := os.ReadFile(infoPlistPth)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to read Info.plist: %w", err)
+	}
+
+	var info infoPlist
+	if _, err := plist.Unmarshal(data, &info); err != nil {
+		return Bundle{}, fmt.Errorf("failed to decode %s: %w", infoPlistPth, err)
+	}
+
+	bundle := Bundle{
+		Path:       pth,
+		Identifier: info.CFBundleIdentifier,
+	}
+
+	if info.CFBundleExecutable == "" {
+		return bundle, nil
+	}
+
+	bundle.ExecutablePath = filepath.Join(executableDir, info.CFBundleExecutable)
+	if bundle.Architectures, err = Architectures(bundle.ExecutablePath); err != nil {
+		return Bundle{}, err
+	}
+
+	return bundle, nil
+}
+
+// Architectures returns the architectures of a thin or universal (fat) Mach-O binary.
+func Architectures(executablePth string) ([]string, error) {
+	fat, err := macho.OpenFat(executablePth)
+	if err == nil {
+		defer fat.Close()
+
+		var archs []string
+		for _, arch := range fat.Arches {
+			archs = append(archs, architectureName(arch.Cpu, arch.SubCpu))
+		}
+		return archs, nil
+	}
+	if !errors.Is(err, macho.ErrNotFat) {
+		return nil, fmt.Errorf("failed to open %s: %w", executablePth, err)
+	}
+
+	thin, err := macho.Open(executablePth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", executablePth, err)
+	}
+	defer thin.Close()
+
+	return []string{architectureName(thin.Cpu, thin.SubCpu)}, nil
+}
+
+const (
+	cpuSubtypeMask   = 0x00ffffff
+	cpuSubtypeArm64E = 2
+)
+
+func architectureName(cpu macho.Cpu, subCpu uint32) string {
+	switch cpu {
+	case macho.CpuArm64:
+		if subCpu&cpuSubtypeMask == cpuSubtypeArm64E {
+			return "arm64e"
+		}
+		return "arm64"
+	case macho.CpuAmd64:
+		return "x86_64"
+	case macho.CpuArm:
+		return "armv7"
+	case macho.Cpu386:
+		return "i386"
+	default:
+		return cpu.String()
+	}
+}
@@ -0,0 +1,124 @@
+package xcbundle
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name              string
+		infoPlistPth      string
+		executablePth     string
+		executable        []byte
+		wantArchitectures []string
+	}{
+		{
+			name:              "iOS app",
+			infoPlistPth:      "Info.plist",
+			executablePth:     "BullsEye",
+			executable:        thinMachO(macho.CpuArm64, 0),
+			wantArchitectures: []string{"arm64"},
+		},
+		{
+			name:              "macOS app",
+			infoPlistPth:      "Contents/Info.plist",
+			executablePth:     "Contents/MacOS/BullsEye",
+			executable:        fatMachO(t, thinMachO(macho.CpuAmd64, 3), thinMachO(macho.CpuArm64, 0)),
+			wantArchitectures: []string{"x86_64", "arm64"},
+		},
+		{
+			name:              "arm64e",
+			infoPlistPth:      "Info.plist",
+			executablePth:     "BullsEye",
+			executable:        thinMachO(macho.CpuArm64, cpuSubtypeArm64E),
+			wantArchitectures: []string{"arm64e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundlePth := filepath.Join(t.TempDir(), "BullsEye.app")
+			writeFile(t, filepath.Join(bundlePth, tt.infoPlistPth), []byte(infoPlistContent("io.bitrise.BullsEye", "BullsEye")))
+			writeFile(t, filepath.Join(bundlePth, tt.executablePth), tt.executable)
+
+			bundle, err := Open(bundlePth)
+
+			require.NoError(t, err)
+			require.Equal(t, Bundle{
+				Path:           bundlePth,
+				Identifier:     "io.bitrise.BullsEye",
+				ExecutablePath: filepath.Join(bundlePth, tt.executablePth),
+				Architectures:  tt.wantArchitectures,
+			}, bundle)
+		})
+	}
+}
+
+func TestOpen_MissingInfoPlist(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "BullsEye.app"))
+	require.ErrorContains(t, err, "failed to read Info.plist")
+}
+
+func TestArchitectures_NotMachO(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "BullsEye")
+	writeFile(t, pth, []byte("#!/bin/sh"))
+
+	_, err := Architectures(pth)
+	require.Error(t, err)
+}
+
+func infoPlistContent(bundleID, executable string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CFBundleExecutable</key>
+	<string>` + executable + `</string>
+	<key>CFBundleIdentifier</key>
+	<string>` + bundleID + `</string>
+</dict>
+</plist>
+`
+}
+
+// thinMachO returns a 64-bit Mach-O executable header without load commands.
+func thinMachO(cpu macho.Cpu, subCpu uint32) []byte {
+	var buf bytes.Buffer
+	for _, field := range []uint32{macho.Magic64, uint32(cpu), subCpu, uint32(macho.TypeExec), 0, 0, 0, 0} {
+		_ = binary.Write(&buf, binary.LittleEndian, field)
+	}
+	return buf.Bytes()
+}
+
+// fatMachO returns a universal binary containing the given thin Mach-O binaries.
+func fatMachO(t *testing.T, thins ...[]byte) []byte {
+	const align = 12
+	var header, body bytes.Buffer
+	require.NoError(t, binary.Write(&header, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(thins))}))
+
+	offset := uint32(1 << align)
+	for _, thin := range thins {
+		f, err := macho.NewFile(bytes.NewReader(thin))
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(&header, binary.BigEndian, []uint32{uint32(f.Cpu), f.SubCpu, offset, uint32(len(thin)), align}))
+
+		padded := make([]byte, 1<<align)
+		copy(padded, thin)
+		body.Write(padded)
+		offset += 1 << align
+	}
+
+	padding := make([]byte, 1<<align-header.Len())
+	return append(append(header.Bytes(), padding...), body.Bytes()...)
+}
+
+func writeFile(t *testing.T, pth string, content []byte) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+	require.NoError(t, os.WriteFile(pth, content, 0755))
+}
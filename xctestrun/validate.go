@@ -0,0 +1,58 @@
+package xctestrun
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validate checks that the xctestrun file describes runnable test targets.
+func (f *File) Validate() error {
+	var issues []string
+
+	if f.FormatVersion != 1 && f.FormatVersion != 2 {
+		issues = append(issues, fmt.Sprintf("unsupported FormatVersion: %d", f.FormatVersion))
+	}
+
+	if len(f.TestTargets()) == 0 {
+		issues = append(issues, "no test targets")
+	}
+
+	for _, configuration := range f.TestConfigurations {
+		names := map[string]bool{}
+		for _, target := range configuration.TestTargets {
+			for _, issue := range target.validate() {
+				issues = append(issues, fmt.Sprintf("%s: %s", target.BlueprintName, issue))
+			}
+
+			if names[target.BlueprintName] {
+				issues = append(issues, fmt.Sprintf("duplicated test target in configuration (%s): %s", configuration.Name, target.BlueprintName))
+			}
+			names[target.BlueprintName] = true
+		}
+	}
+
+	if len(issues) > 0 {
+		return errors.New("invalid xctestrun:\n- " + strings.Join(issues, "\n- "))
+	}
+	return nil
+}
+
+func (t *TestTarget) validate() []string {
+	var issues []string
+
+	if t.BlueprintName == "" {
+		issues = append(issues, "missing BlueprintName")
+	}
+	if t.TestBundlePath == "" {
+		issues = append(issues, "missing TestBundlePath")
+	}
+	if (t.IsUITestBundle || t.IsAppHostedTestBundle) && t.TestHostPath == "" {
+		issues = append(issues, "missing TestHostPath")
+	}
+	if strings.Contains(t.TestBundlePath, TestHostPlaceholder) && t.TestHostPath == "" {
+		issues = append(issues, fmt.Sprintf("TestBundlePath references %s, but TestHostPath is missing", TestHostPlaceholder))
+	}
+
+	return issues
+}
@@ -0,0 +1,137 @@
+package xctestrun
+
+func mapValue(m map[string]interface{}, key string) map[string]interface{} {
+	value, _ := m[key].(map[string]interface{})
+	return value
+}
+
+func stringValue(m map[string]interface{}, key string) string {
+	value, _ := m[key].(string)
+	return value
+}
+
+func boolValue(m map[string]interface{}, key string) bool {
+	value, _ := m[key].(bool)
+	return value
+}
+
+func intValue(m map[string]interface{}, key string) int {
+	switch value := m[key].(type) {
+	case uint64:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	default:
+		return 0
+	}
+}
+
+func stringSliceValue(m map[string]interface{}, key string) []string {
+	values, ok := m[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var strs []string
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+func stringMapValue(m map[string]interface{}, key string) map[string]string {
+	values, ok := m[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	strs := map[string]string{}
+	for k, value := range values {
+		if str, ok := value.(string); ok {
+			strs[k] = str
+		}
+	}
+	return strs
+}
+
+// The setters below only add a key if it has a non-empty value or if it was already present in the original file,
+// so that re-encoding an unmodified file does not introduce new keys.
+
+func setString(m map[string]interface{}, key, value string) {
+	if _, ok := m[key]; ok || value != "" {
+		m[key] = value
+	}
+}
+
+func setBool(m map[string]interface{}, key string, value bool) {
+	if _, ok := m[key]; ok || value {
+		m[key] = value
+	}
+}
+
+func setStringSlice(m map[string]interface{}, key string, value []string) {
+	if _, ok := m[key]; ok || len(value) > 0 {
+		if value == nil {
+			value = []string{}
+		}
+		m[key] = value
+	}
+}
+
+func setStringMap(m map[string]interface{}, key string, value map[string]string) {
+	if _, ok := m[key]; ok || len(value) > 0 {
+		if value == nil {
+			value = map[string]string{}
+		}
+		m[key] = value
+	}
+}
+
+// nonPathKeys are the keys of names, bundle identifiers and test identifiers, their values are not rewritten as paths.
+var nonPathKeys = map[string]bool{
+	metadataKey:                true,
+	testPlanKey:                true,
+	"Name":                     true,
+	"BlueprintName":            true,
+	"BlueprintProviderName":    true,
+	"ProductModuleName":        true,
+	"TestHostBundleIdentifier": true,
+	"OnlyTestIdentifiers":      true,
+	"SkipTestIdentifiers":      true,
+}
+
+// rewriteStrings applies rewrite to every string in the (possibly nested) plist value, modifying maps and arrays in place.
+// The values of the nonPathKeys are kept.
+func rewriteStrings(value interface{}, rewrite func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return rewrite(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			if nonPathKeys[key] {
+				continue
+			}
+			v[key] = rewriteStrings(item, rewrite)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = rewriteStrings(item, rewrite)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		c[key] = value
+	}
+	return c
+}
@@ -0,0 +1,362 @@
+package xctestrun
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	plist "github.com/bitrise-io/go-plist"
+)
+
+const (
+	// TestRootPlaceholder is substituted by the directory containing the xctestrun file when running the tests.
+	TestRootPlaceholder = "__TESTROOT__"
+	// TestHostPlaceholder is substituted by the test host's path when running the tests.
+	TestHostPlaceholder = "__TESTHOST__"
+
+	metadataKey           = "__xctestrun_metadata__"
+	testConfigurationsKey = "TestConfigurations"
+	testPlanKey           = "TestPlan"
+	testTargetsKey        = "TestTargets"
+)
+
+// File is the typed representation of an xctestrun file.
+//
+// FormatVersion 1 files describe the test targets as top level dictionaries (keyed by the target name),
+// FormatVersion 2 files group the test targets into TestConfigurations and reference the Test Plan they were generated from.
+// Both layouts are represented by TestConfigurations, FormatVersion 1 files have a single unnamed configuration.
+//
+// Keys without a typed counterpart are preserved, so a parsed file can be re-encoded without losing information.
+type File struct {
+	FormatVersion      int
+	TestPlan           *TestPlan
+	TestConfigurations []*TestConfiguration
+
+	format int
+	raw    map[string]interface{}
+}
+
+// TestPlan describes the Test Plan the xctestrun file was generated from (FormatVersion 2 only).
+type TestPlan struct {
+	Name      string
+	IsDefault bool
+
+	raw map[string]interface{}
+}
+
+// TestConfiguration is a named group of test targets (FormatVersion 2) or the list of all test targets (FormatVersion 1).
+type TestConfiguration struct {
+	Name        string
+	TestTargets []*TestTarget
+
+	raw map[string]interface{}
+}
+
+// TestTarget describes how to run a single test bundle.
+type TestTarget struct {
+	BlueprintName               string
+	ProductModuleName           string
+	TestBundlePath              string
+	TestHostPath                string
+	TestHostBundleIdentifier    string
+	UITargetAppPath             string
+	DependentProductPaths       []string
+	IsUITestBundle              bool
+	IsAppHostedTestBundle       bool
+	IsXCTRunnerHostedTestBundle bool
+	ParallelizationEnabled      bool
+	EnvironmentVariables        map[string]string
+	TestingEnvironmentVariables map[string]string
+	CommandLineArguments        []string
+	OnlyTestIdentifiers         []string
+	SkipTestIdentifiers         []string
+
+	// key is the top level dictionary key of the target in FormatVersion 1 files.
+	key string
+	raw map[string]interface{}
+}
+
+// Parse decodes an xctestrun file's content.
+func Parse(data []byte) (*File, error) {
+	var raw map[string]interface{}
+	format, err := plist.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode xctestrun: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("xctestrun is empty")
+	}
+
+	file := &File{
+		format: format,
+		raw:    raw,
+	}
+
+	metadata, _ := raw[metadataKey].(map[string]interface{})
+	file.FormatVersion = intValue(metadata, "FormatVersion")
+
+	switch file.FormatVersion {
+	case 1:
+		file.TestConfigurations = []*TestConfiguration{parseFormatVersion1Targets(raw)}
+	case 2:
+		if planRaw, ok := raw[testPlanKey].(map[string]interface{}); ok {
+			file.TestPlan = &TestPlan{
+				Name:      stringValue(planRaw, "Name"),
+				IsDefault: boolValue(planRaw, "IsDefault"),
+				raw:       planRaw,
+			}
+		}
+
+		configurationsRaw, _ := raw[testConfigurationsKey].([]interface{})
+		for _, configurationRaw := range configurationsRaw {
+			configurationMap, ok := configurationRaw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid %s entry: %v", testConfigurationsKey, configurationRaw)
+			}
+
+			configuration := &TestConfiguration{
+				Name: stringValue(configurationMap, "Name"),
+				raw:  configurationMap,
+			}
+
+			targetsRaw, _ := configurationMap[testTargetsKey].([]interface{})
+			for _, targetRaw := range targetsRaw {
+				targetMap, ok := targetRaw.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid %s entry in %s configuration: %v", testTargetsKey, configuration.Name, targetRaw)
+				}
+				configuration.TestTargets = append(configuration.TestTargets, parseTestTarget(targetMap, ""))
+			}
+
+			file.TestConfigurations = append(file.TestConfigurations, configuration)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported xctestrun FormatVersion: %d", file.FormatVersion)
+	}
+
+	return file, nil
+}
+
+func parseFormatVersion1Targets(raw map[string]interface{}) *TestConfiguration {
+	var keys []string
+	for key, value := range raw {
+		if key == metadataKey {
+			continue
+		}
+		if _, ok := value.(map[string]interface{}); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	configuration := &TestConfiguration{}
+	for _, key := range keys {
+		configuration.TestTargets = append(configuration.TestTargets, parseTestTarget(raw[key].(map[string]interface{}), key))
+		// targets are added back by Marshal, based on the actual TestTargets list
+		delete(raw, key)
+	}
+
+	return configuration
+}
+
+func parseTestTarget(raw map[string]interface{}, key string) *TestTarget {
+	target := &TestTarget{
+		BlueprintName:               stringValue(raw, "BlueprintName"),
+		ProductModuleName:           stringValue(raw, "ProductModuleName"),
+		TestBundlePath:              stringValue(raw, "TestBundlePath"),
+		TestHostPath:                stringValue(raw, "TestHostPath"),
+		TestHostBundleIdentifier:    stringValue(raw, "TestHostBundleIdentifier"),
+		UITargetAppPath:             stringValue(raw, "UITargetAppPath"),
+		DependentProductPaths:       stringSliceValue(raw, "DependentProductPaths"),
+		IsUITestBundle:              boolValue(raw, "IsUITestBundle"),
+		IsAppHostedTestBundle:       boolValue(raw, "IsAppHostedTestBundle"),
+		IsXCTRunnerHostedTestBundle: boolValue(raw, "IsXCTRunnerHostedTestBundle"),
+		ParallelizationEnabled:      boolValue(raw, "ParallelizationEnabled"),
+		EnvironmentVariables:        stringMapValue(raw, "EnvironmentVariables"),
+		TestingEnvironmentVariables: stringMapValue(raw, "TestingEnvironmentVariables"),
+		CommandLineArguments:        stringSliceValue(raw, "CommandLineArguments"),
+		OnlyTestIdentifiers:         stringSliceValue(raw, "OnlyTestIdentifiers"),
+		SkipTestIdentifiers:         stringSliceValue(raw, "SkipTestIdentifiers"),
+		key:                         key,
+		raw:                         raw,
+	}
+	if target.BlueprintName == "" {
+		target.BlueprintName = key
+	}
+	return target
+}
+
+// Marshal encodes the xctestrun file, using the format (XML or binary) it was parsed from.
+func (f *File) Marshal() ([]byte, error) {
+	raw := copyMap(f.raw)
+
+	metadata := copyMap(mapValue(raw, metadataKey))
+	metadata["FormatVersion"] = f.FormatVersion
+	raw[metadataKey] = metadata
+
+	switch f.FormatVersion {
+	case 1:
+		for _, configuration := range f.TestConfigurations {
+			for _, target := range configuration.TestTargets {
+				key := target.key
+				if key == "" {
+					key = target.BlueprintName
+				}
+				raw[key] = target.marshal()
+			}
+		}
+	case 2:
+		if f.TestPlan != nil {
+			plan := copyMap(f.TestPlan.raw)
+			setString(plan, "Name", f.TestPlan.Name)
+			setBool(plan, "IsDefault", f.TestPlan.IsDefault)
+			raw[testPlanKey] = plan
+		} else {
+			delete(raw, testPlanKey)
+		}
+
+		configurations := make([]interface{}, 0, len(f.TestConfigurations))
+		for _, configuration := range f.TestConfigurations {
+			configurationRaw := copyMap(configuration.raw)
+			setString(configurationRaw, "Name", configuration.Name)
+
+			targets := make([]interface{}, 0, len(configuration.TestTargets))
+			for _, target := range configuration.TestTargets {
+				targets = append(targets, target.marshal())
+			}
+			configurationRaw[testTargetsKey] = targets
+
+			configurations = append(configurations, configurationRaw)
+		}
+		raw[testConfigurationsKey] = configurations
+	default:
+		return nil, fmt.Errorf("unsupported xctestrun FormatVersion: %d", f.FormatVersion)
+	}
+
+	format := f.format
+	if format == 0 {
+		format = plist.XMLFormat
+	}
+
+	return plist.MarshalIndent(raw, format, "\t")
+}
+
+func (t *TestTarget) marshal() map[string]interface{} {
+	raw := copyMap(t.raw)
+	// FormatVersion 1 targets without a BlueprintName key default to their dictionary key, which is not written back
+	if _, ok := t.raw["BlueprintName"]; ok || t.BlueprintName != t.key {
+		setString(raw, "BlueprintName", t.BlueprintName)
+	}
+	setString(raw, "ProductModuleName", t.ProductModuleName)
+	setString(raw, "TestBundlePath", t.TestBundlePath)
+	setString(raw, "TestHostPath", t.TestHostPath)
+	setString(raw, "TestHostBundleIdentifier", t.TestHostBundleIdentifier)
+	setString(raw, "UITargetAppPath", t.UITargetAppPath)
+	setStringSlice(raw, "DependentProductPaths", t.DependentProductPaths)
+	setBool(raw, "IsUITestBundle", t.IsUITestBundle)
+	setBool(raw, "IsAppHostedTestBundle", t.IsAppHostedTestBundle)
+	setBool(raw, "IsXCTRunnerHostedTestBundle", t.IsXCTRunnerHostedTestBundle)
+	setBool(raw, "ParallelizationEnabled", t.ParallelizationEnabled)
+	setStringMap(raw, "EnvironmentVariables", t.EnvironmentVariables)
+	setStringMap(raw, "TestingEnvironmentVariables", t.TestingEnvironmentVariables)
+	setStringSlice(raw, "CommandLineArguments", t.CommandLineArguments)
+	setStringSlice(raw, "OnlyTestIdentifiers", t.OnlyTestIdentifiers)
+	setStringSlice(raw, "SkipTestIdentifiers", t.SkipTestIdentifiers)
+	return raw
+}
+
+// TestTargets returns the test targets of every test configuration.
+func (f *File) TestTargets() []*TestTarget {
+	var targets []*TestTarget
+	for _, configuration := range f.TestConfigurations {
+		targets = append(targets, configuration.TestTargets...)
+	}
+	return targets
+}
+
+// RewritePaths replaces every string value of the xctestrun file with the value returned by rewrite,
+// except for the names, bundle identifiers and test identifiers (and the metadata and Test Plan), which are not paths.
+// This covers the product paths and environment variables of the test targets,
+// as well as keys without a typed counterpart (for example CodeCoverageBuildableInfos' ProductPaths).
+func (f *File) RewritePaths(rewrite func(pth string) string) {
+	for key, value := range f.raw {
+		// the test configurations are rewritten one by one below, FormatVersion 1 targets are not part of raw
+		if key == testConfigurationsKey || nonPathKeys[key] {
+			continue
+		}
+		f.raw[key] = rewriteStrings(value, rewrite)
+	}
+
+	for _, configuration := range f.TestConfigurations {
+		for key, value := range configuration.raw {
+			if key == testTargetsKey || nonPathKeys[key] {
+				continue
+			}
+			configuration.raw[key] = rewriteStrings(value, rewrite)
+		}
+
+		for _, target := range configuration.TestTargets {
+			target.rewritePaths(rewrite)
+		}
+	}
+}
+
+func (t *TestTarget) rewritePaths(rewrite func(pth string) string) {
+	for key, value := range t.raw {
+		if nonPathKeys[key] {
+			continue
+		}
+		t.raw[key] = rewriteStrings(value, rewrite)
+	}
+
+	t.TestBundlePath = rewrite(t.TestBundlePath)
+	t.TestHostPath = rewrite(t.TestHostPath)
+	t.UITargetAppPath = rewrite(t.UITargetAppPath)
+	for i, pth := range t.DependentProductPaths {
+		t.DependentProductPaths[i] = rewrite(pth)
+	}
+	// DYLD_FRAMEWORK_PATH, DYLD_LIBRARY_PATH and similar variables refer to the build products too
+	for key, value := range t.EnvironmentVariables {
+		t.EnvironmentVariables[key] = rewrite(value)
+	}
+	for key, value := range t.TestingEnvironmentVariables {
+		t.TestingEnvironmentVariables[key] = rewrite(value)
+	}
+	for i, argument := range t.CommandLineArguments {
+		t.CommandLineArguments[i] = rewrite(argument)
+	}
+}
+
+// ProductPath is a build product path referenced by a test target.
+type ProductPath struct {
+	// Key is the xctestrun key the path is stored under (for example TestHostPath).
+	Key  string
+	Path string
+}
+
+// ProductPaths returns every build product path referenced by the test target.
+func (t *TestTarget) ProductPaths() []ProductPath {
+	var paths []ProductPath
+	for _, productPath := range []ProductPath{
+		{Key: "TestHostPath", Path: t.TestHostPath},
+		{Key: "TestBundlePath", Path: t.TestBundlePath},
+		{Key: "UITargetAppPath", Path: t.UITargetAppPath},
+	} {
+		if productPath.Path != "" {
+			paths = append(paths, productPath)
+		}
+	}
+	for _, pth := range t.DependentProductPaths {
+		paths = append(paths, ProductPath{Key: "DependentProductPaths", Path: pth})
+	}
+	return paths
+}
+
+// ResolvePath expands the __TESTROOT__ and __TESTHOST__ placeholders of a path referenced by the test target.
+// testRoot is the directory containing the xctestrun file.
+func (t *TestTarget) ResolvePath(pth, testRoot string) string {
+	if strings.Contains(pth, TestHostPlaceholder) && t.TestHostPath != "" && !strings.Contains(t.TestHostPath, TestHostPlaceholder) {
+		pth = strings.Replace(pth, TestHostPlaceholder, t.TestHostPath, -1)
+	}
+	return strings.Replace(pth, TestRootPlaceholder, testRoot, -1)
+}
@@ -0,0 +1,411 @@
+package xctestrun
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	plist "github.com/bitrise-io/go-plist"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	formatVersion1Fixture = "BullsEye_iphonesimulator15.5-arm64.xctestrun"
+	formatVersion2Fixture = "BullsEye_FullTests_iphonesimulator15.5-arm64.xctestrun"
+)
+
+func Test_GivenFormatVersion1Xctestrun_WhenParse_ThenReturnsTestTargets(t *testing.T) {
+	// When
+	file, err := Parse(readFixture(t, formatVersion1Fixture))
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, 1, file.FormatVersion)
+	require.Nil(t, file.TestPlan)
+	require.Len(t, file.TestConfigurations, 1)
+
+	targets := file.TestTargets()
+	require.Len(t, targets, 2)
+
+	unitTests := targets[0]
+	require.Equal(t, "BullsEyeTests", unitTests.BlueprintName)
+	require.Equal(t, "__TESTHOST__/PlugIns/BullsEyeTests.xctest", unitTests.TestBundlePath)
+	require.Equal(t, "/private__TESTROOT__/Debug-iphonesimulator/BullsEye.app", unitTests.TestHostPath)
+	require.Equal(t, "io.bitrise.BullsEye", unitTests.TestHostBundleIdentifier)
+	require.True(t, unitTests.IsAppHostedTestBundle)
+	require.False(t, unitTests.IsUITestBundle)
+	require.Equal(t, map[string]string{"OS_ACTIVITY_DT_MODE": "YES"}, unitTests.EnvironmentVariables)
+
+	uiTests := targets[1]
+	require.Equal(t, "BullsEyeUITests", uiTests.BlueprintName)
+	require.True(t, uiTests.IsUITestBundle)
+	require.Equal(t, "/private__TESTROOT__/Debug-iphonesimulator/BullsEye.app", uiTests.UITargetAppPath)
+	require.Equal(t, []string{"BullsEyeUITests/testLaunchPerformance"}, uiTests.SkipTestIdentifiers)
+	require.Empty(t, uiTests.OnlyTestIdentifiers)
+}
+
+func Test_GivenFormatVersion2Xctestrun_WhenParse_ThenReturnsTestConfigurations(t *testing.T) {
+	// When
+	file, err := Parse(readFixture(t, formatVersion2Fixture))
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, 2, file.FormatVersion)
+	require.Equal(t, &TestPlan{Name: "FullTests", IsDefault: true, raw: file.TestPlan.raw}, file.TestPlan)
+	require.Len(t, file.TestConfigurations, 1)
+	require.Equal(t, "Test Scheme Action", file.TestConfigurations[0].Name)
+
+	targets := file.TestTargets()
+	require.Len(t, targets, 2)
+	require.Equal(t, "BullsEyeTests", targets[0].BlueprintName)
+	require.True(t, targets[0].ParallelizationEnabled)
+	require.Equal(t, []string{
+		"__TESTROOT__/Debug-iphonesimulator/BullsEye.app",
+		"__TESTROOT__/Debug-iphonesimulator/BullsEye.app/PlugIns/BullsEyeTests.xctest",
+	}, targets[0].DependentProductPaths)
+	require.Equal(t, "BullsEyeUITests", targets[1].BlueprintName)
+	require.True(t, targets[1].IsUITestBundle)
+	require.Equal(t, "__TESTROOT__/Debug-iphonesimulator/BullsEyeUITests-Runner.app", targets[1].TestHostPath)
+}
+
+func Test_GivenParsedXctestrun_WhenMarshal_ThenPreservesContent(t *testing.T) {
+	for _, fixture := range []string{formatVersion1Fixture, formatVersion2Fixture} {
+		t.Run(fixture, func(t *testing.T) {
+			data := readFixture(t, fixture)
+			file, err := Parse(data)
+			require.NoError(t, err)
+
+			// When
+			encoded, err := file.Marshal()
+
+			// Then
+			require.NoError(t, err)
+			requirePlistEqual(t, data, encoded)
+		})
+	}
+}
+
+func Test_GivenFormatVersion1XctestrunWithoutBlueprintName_WhenMarshal_ThenNoKeyIsAdded(t *testing.T) {
+	// Given
+	data := []byte(plistDocument(`<dict>
+	<key>BullsEyeTests</key>
+	<dict>
+		<key>TestBundlePath</key>
+		<string>__TESTHOST__/PlugIns/BullsEyeTests.xctest</string>
+		<key>TestHostPath</key>
+		<string>__TESTROOT__/Debug-iphonesimulator/BullsEye.app</string>
+	</dict>
+	<key>__xctestrun_metadata__</key>
+	<dict>
+		<key>FormatVersion</key>
+		<integer>1</integer>
+	</dict>
+</dict>`))
+	file, err := Parse(data)
+	require.NoError(t, err)
+	require.Equal(t, "BullsEyeTests", file.TestTargets()[0].BlueprintName)
+
+	// When
+	encoded, err := file.Marshal()
+
+	// Then
+	require.NoError(t, err)
+	requirePlistEqual(t, data, encoded)
+}
+
+func Test_GivenBinaryXctestrun_WhenMarshal_ThenKeepsBinaryFormat(t *testing.T) {
+	// Given
+	var raw map[string]interface{}
+	_, err := plist.Unmarshal(readFixture(t, formatVersion2Fixture), &raw)
+	require.NoError(t, err)
+	binaryData, err := plist.Marshal(raw, plist.BinaryFormat)
+	require.NoError(t, err)
+
+	file, err := Parse(binaryData)
+	require.NoError(t, err)
+
+	// When
+	encoded, err := file.Marshal()
+
+	// Then
+	require.NoError(t, err)
+	format, err := plist.Unmarshal(encoded, &raw)
+	require.NoError(t, err)
+	require.Equal(t, plist.BinaryFormat, format)
+}
+
+func Test_GivenModifiedTestTargets_WhenMarshal_ThenWritesChanges(t *testing.T) {
+	for _, fixture := range []string{formatVersion1Fixture, formatVersion2Fixture} {
+		t.Run(fixture, func(t *testing.T) {
+			// Given
+			file, err := Parse(readFixture(t, fixture))
+			require.NoError(t, err)
+
+			target := file.TestTargets()[0]
+			target.OnlyTestIdentifiers = []string{"BullsEyeTests/testScoreIsComputed"}
+			target.EnvironmentVariables["API_URL"] = "https://example.com"
+			file.TestConfigurations[0].TestTargets = file.TestConfigurations[0].TestTargets[:1]
+
+			// When
+			encoded, err := file.Marshal()
+
+			// Then
+			require.NoError(t, err)
+			reparsed, err := Parse(encoded)
+			require.NoError(t, err)
+			require.Len(t, reparsed.TestTargets(), 1)
+			require.Equal(t, []string{"BullsEyeTests/testScoreIsComputed"}, reparsed.TestTargets()[0].OnlyTestIdentifiers)
+			require.Equal(t, "https://example.com", reparsed.TestTargets()[0].EnvironmentVariables["API_URL"])
+		})
+	}
+}
+
+func Test_GivenPrivateTestRoot_WhenRewritePaths_ThenAllPathsAreRewritten(t *testing.T) {
+	// Given
+	file, err := Parse(readFixture(t, formatVersion1Fixture))
+	require.NoError(t, err)
+
+	// When
+	file.RewritePaths(func(pth string) string {
+		return strings.Replace(pth, "/private"+TestRootPlaceholder, TestRootPlaceholder, -1)
+	})
+
+	// Then
+	encoded, err := file.Marshal()
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), "/private__TESTROOT__")
+
+	uiTests := file.TestTargets()[1]
+	require.Equal(t, "__TESTROOT__/Debug-iphonesimulator/BullsEyeUITests-Runner.app", uiTests.TestHostPath)
+	require.Equal(t, "__TESTROOT__/Debug-iphonesimulator/BullsEye.app", uiTests.UITargetAppPath)
+	require.Equal(t, "__TESTROOT__/Debug-iphonesimulator:", file.TestTargets()[0].TestingEnvironmentVariables["DYLD_FRAMEWORK_PATH"])
+}
+
+func Test_GivenFormatVersion2XctestrunWithPrivateTestRoot_WhenRewritePaths_ThenUntypedPathsAreRewritten(t *testing.T) {
+	// Given
+	data := strings.Replace(string(readFixture(t, formatVersion2Fixture)), TestRootPlaceholder, "/private"+TestRootPlaceholder, -1)
+	file, err := Parse([]byte(data))
+	require.NoError(t, err)
+
+	// When
+	file.RewritePaths(func(pth string) string {
+		return strings.Replace(pth, "/private"+TestRootPlaceholder, TestRootPlaceholder, -1)
+	})
+
+	// Then
+	encoded, err := file.Marshal()
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), "/private__TESTROOT__")
+
+	var raw struct {
+		CodeCoverageBuildableInfos []struct {
+			ProductPaths []string
+		}
+	}
+	_, err = plist.Unmarshal(encoded, &raw)
+	require.NoError(t, err)
+	require.Equal(t, []string{"__TESTROOT__/Debug-iphonesimulator/BullsEye.app/BullsEye"}, raw.CodeCoverageBuildableInfos[0].ProductPaths)
+	require.Equal(t, "__TESTROOT__/Debug-iphonesimulator/BullsEyeUITests-Runner.app", file.TestTargets()[1].TestHostPath)
+}
+
+func Test_GivenFormatVersion2Xctestrun_WhenRewritePaths_ThenNamesAreKept(t *testing.T) {
+	// Given
+	file, err := Parse(readFixture(t, formatVersion2Fixture))
+	require.NoError(t, err)
+
+	// When
+	file.RewritePaths(func(pth string) string {
+		return "/rewritten" + pth
+	})
+
+	// Then
+	encoded, err := file.Marshal()
+	require.NoError(t, err)
+
+	var raw struct {
+		CodeCoverageBuildableInfos []struct {
+			Name         string
+			ProductPaths []string
+		}
+		TestConfigurations []struct {
+			Name        string
+			TestTargets []struct {
+				BlueprintName string
+			}
+		}
+		TestPlan struct {
+			Name string
+		}
+	}
+	_, err = plist.Unmarshal(encoded, &raw)
+	require.NoError(t, err)
+	require.Equal(t, "BullsEye.app", raw.CodeCoverageBuildableInfos[0].Name)
+	require.Equal(t, []string{"/rewritten__TESTROOT__/Debug-iphonesimulator/BullsEye.app/BullsEye"}, raw.CodeCoverageBuildableInfos[0].ProductPaths)
+	require.Equal(t, "Test Scheme Action", raw.TestConfigurations[0].Name)
+	require.Equal(t, "BullsEyeTests", raw.TestConfigurations[0].TestTargets[0].BlueprintName)
+	require.Equal(t, "FullTests", raw.TestPlan.Name)
+}
+
+func Test_Parse_InvalidContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			data:    "",
+			wantErr: "xctestrun is empty",
+		},
+		{
+			name:    "not a property list",
+			data:    "garbage <",
+			wantErr: "failed to decode xctestrun",
+		},
+		{
+			name:    "missing metadata",
+			data:    plistDocument(`<dict><key>TestConfigurations</key><array/></dict>`),
+			wantErr: "unsupported xctestrun FormatVersion: 0",
+		},
+		{
+			name:    "invalid test configuration",
+			data:    plistDocument(`<dict><key>TestConfigurations</key><array><string>config</string></array><key>__xctestrun_metadata__</key><dict><key>FormatVersion</key><integer>2</integer></dict></dict>`),
+			wantErr: "invalid TestConfigurations entry",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestFile_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []*TestTarget
+		wantErr []string
+	}{
+		{
+			name: "valid",
+			targets: []*TestTarget{
+				{BlueprintName: "UnitTests", TestBundlePath: "__TESTROOT__/Debug-iphonesimulator/UnitTests.xctest"},
+			},
+		},
+		{
+			name:    "no test targets",
+			wantErr: []string{"no test targets"},
+		},
+		{
+			name: "missing paths",
+			targets: []*TestTarget{
+				{BlueprintName: "UITests", IsUITestBundle: true},
+				{BlueprintName: "HostedTests", TestBundlePath: "__TESTHOST__/PlugIns/HostedTests.xctest"},
+			},
+			wantErr: []string{
+				"UITests: missing TestBundlePath",
+				"UITests: missing TestHostPath",
+				"HostedTests: TestBundlePath references __TESTHOST__, but TestHostPath is missing",
+			},
+		},
+		{
+			name: "duplicated test target",
+			targets: []*TestTarget{
+				{BlueprintName: "UnitTests", TestBundlePath: "__TESTROOT__/Debug-iphonesimulator/UnitTests.xctest"},
+				{BlueprintName: "UnitTests", TestBundlePath: "__TESTROOT__/Debug-iphonesimulator/UnitTests.xctest"},
+			},
+			wantErr: []string{"duplicated test target in configuration (Test Scheme Action): UnitTests"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &File{
+				FormatVersion: 2,
+				TestConfigurations: []*TestConfiguration{
+					{Name: "Test Scheme Action", TestTargets: tt.targets},
+				},
+			}
+
+			err := file.Validate()
+			if len(tt.wantErr) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, wantErr := range tt.wantErr {
+				require.Contains(t, err.Error(), wantErr)
+			}
+		})
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+func requirePlistEqual(t *testing.T, expected, actual []byte) {
+	var expectedRaw, actualRaw map[string]interface{}
+	_, err := plist.Unmarshal(expected, &expectedRaw)
+	require.NoError(t, err)
+	_, err = plist.Unmarshal(actual, &actualRaw)
+	require.NoError(t, err)
+	require.Equal(t, expectedRaw, actualRaw)
+}
+
+func plistDocument(content string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">` + content + `</plist>`
+}
+
+func TestTestTarget_ResolvePath(t *testing.T) {
+	target := &TestTarget{
+		TestHostPath: "__TESTROOT__/Debug-iphonesimulator/BullsEye.app",
+	}
+
+	tests := []struct {
+		name string
+		pth  string
+		want string
+	}{
+		{
+			name: "test root",
+			pth:  "__TESTROOT__/Debug-iphonesimulator/BullsEye.app",
+			want: "/symroot/Debug-iphonesimulator/BullsEye.app",
+		},
+		{
+			name: "test host",
+			pth:  "__TESTHOST__/PlugIns/BullsEyeTests.xctest",
+			want: "/symroot/Debug-iphonesimulator/BullsEye.app/PlugIns/BullsEyeTests.xctest",
+		},
+		{
+			name: "absolute path",
+			pth:  "/Applications/Xcode.app",
+			want: "/Applications/Xcode.app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, target.ResolvePath(tt.pth, "/symroot"))
+		})
+	}
+}
+
+func TestTestTarget_ProductPaths(t *testing.T) {
+	file, err := Parse(readFixture(t, formatVersion2Fixture))
+	require.NoError(t, err)
+
+	require.Equal(t, []ProductPath{
+		{Key: "TestHostPath", Path: "__TESTROOT__/Debug-iphonesimulator/BullsEyeUITests-Runner.app"},
+		{Key: "TestBundlePath", Path: "__TESTHOST__/PlugIns/BullsEyeUITests.xctest"},
+		{Key: "UITargetAppPath", Path: "__TESTROOT__/Debug-iphonesimulator/BullsEye.app"},
+		{Key: "DependentProductPaths", Path: "__TESTROOT__/Debug-iphonesimulator/BullsEye.app"},
+		{Key: "DependentProductPaths", Path: "__TESTROOT__/Debug-iphonesimulator/BullsEyeUITests-Runner.app/PlugIns/BullsEyeUITests.xctest"},
+	}, file.TestTargets()[1].ProductPaths())
+}